@@ -33,15 +33,21 @@ type collabGraph = graph.Graph[uint32, struct{}] // a graph of user -> repos
 type repoGraph = graph.Graph[uint32, struct{}] // A graph of repo -> users
 
 
-func ReadCollabGraphToUserGraph(filename string) userGraph {	
-	var collabGraph userGraph = graph.ReadNeighborGraph[uint32](filename)
+func ReadCollabGraphToUserGraph(filename string) (userGraph, error) {	
+	collabGraph, err := graph.ReadNeighborGraph[uint32](filename)
+	if err != nil {
+		return nil, err
+	}
 	var repoGraph repoGraph = ConvertCollabToRepoGraph(collabGraph)
-	return ConvertRepoToUserGraph(repoGraph)
+	return ConvertRepoToUserGraph(repoGraph), nil
 }
 
-func ReadCollabToRepoGraph(filename string) repoGraph {
-	var collabGraph userGraph = graph.ReadNeighborGraph[uint32](filename)
-	return ConvertCollabToRepoGraph(collabGraph)
+func ReadCollabToRepoGraph(filename string) (repoGraph, error) {
+	collabGraph, err := graph.ReadNeighborGraph[uint32](filename)
+	if err != nil {
+		return nil, err
+	}
+	return ConvertCollabToRepoGraph(collabGraph), nil
 }
 
 func ConvertCollabToRepoGraph(graph collabGraph) repoGraph {
@@ -0,0 +1,533 @@
+package graph
+
+/*
+Implements Arrow IPC output, the file format (.arrow) and the stream format (.arrows),
+for loading edges and events into Arrow based tools without CGO or an Arrow module.
+
+Rows are structs whose fields are tagged with their column name (`arrow:"src"`), as with
+Parquet. integers, floats, bools and strings are supported, pointers to them are nullable
+columns, and int64 fields tagged `arrow:"name,timestamp"` are UTC timestamps in milliseconds.
+Rows are buffered into record batches of arrowBatch rows, written uncompressed.
+
+The IPC metadata is flatbuffers, encoded by the minimal encoder below rather than generated
+code, as only a handful of tables of the Arrow schema are written.
+*/
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+const arrowBatch = 64 * 1024
+
+// A minimal flatbuffers encoder. objects are laid out front to back, each table followed by
+// the objects it refers to, as flatbuffers offsets only point forward.
+type fbBuilder struct {
+	buf []byte
+}
+
+// An object written into a builder, returning its position.
+type fbObject interface {
+	encode(b *fbBuilder) int
+}
+
+func (b *fbBuilder) align(n int) {
+	for len(b.buf)%n != 0 {
+		b.buf = append(b.buf, 0)
+	}
+}
+
+// Points the offset at position at to the object at pos.
+func (b *fbBuilder) patch(at int, pos int) {
+	binary.LittleEndian.PutUint32(b.buf[at:], uint32(pos-at))
+}
+
+// A table field, either a scalar of size bytes or an offset to an object. the zero field is absent.
+type fbField struct {
+	size   int
+	value  uint64
+	object fbObject
+}
+
+func fbScalar(size int, value uint64) fbField {
+	return fbField{size: size, value: value}
+}
+
+func fbRef(object fbObject) fbField {
+	return fbField{size: 4, object: object}
+}
+
+// A table, its fields indexed by field id.
+type fbTable []fbField
+
+func (t fbTable) encode(b *fbBuilder) int {
+	offsets := make([]int, len(t))
+	size := 4 // the vtable offset
+	for i, field := range t {
+		if field.size == 0 {
+			continue
+		}
+		size = (size + field.size - 1) / field.size * field.size
+		offsets[i] = size
+		size += field.size
+	}
+
+	b.align(2)
+	vtable := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(4+2*len(t)))
+	b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(size))
+	for _, offset := range offsets {
+		b.buf = binary.LittleEndian.AppendUint16(b.buf, uint16(offset))
+	}
+	b.align(8)
+	table := len(b.buf)
+	b.buf = append(b.buf, make([]byte, size)...)
+	binary.LittleEndian.PutUint32(b.buf[table:], uint32(table-vtable))
+	for i, field := range t {
+		at := table + offsets[i]
+		switch {
+		case field.object != nil || field.size == 0:
+		case field.size == 1:
+			b.buf[at] = byte(field.value)
+		case field.size == 2:
+			binary.LittleEndian.PutUint16(b.buf[at:], uint16(field.value))
+		case field.size == 4:
+			binary.LittleEndian.PutUint32(b.buf[at:], uint32(field.value))
+		case field.size == 8:
+			binary.LittleEndian.PutUint64(b.buf[at:], field.value)
+		}
+	}
+	for i, field := range t {
+		if field.object != nil {
+			b.patch(table+offsets[i], field.object.encode(b))
+		}
+	}
+	return table
+}
+
+type fbString string
+
+func (s fbString) encode(b *fbBuilder) int {
+	b.align(4)
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(s)))
+	b.buf = append(b.buf, s...)
+	b.buf = append(b.buf, 0)
+	return pos
+}
+
+// A vector of count structs of 8 byte aligned fields, already encoded in data.
+type fbStructs struct {
+	count int
+	data  []byte
+}
+
+func (v fbStructs) encode(b *fbBuilder) int {
+	for (len(b.buf)+4)%8 != 0 {
+		b.buf = append(b.buf, 0)
+	}
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(v.count))
+	b.buf = append(b.buf, v.data...)
+	return pos
+}
+
+// A vector of objects.
+type fbVector []fbObject
+
+func (v fbVector) encode(b *fbBuilder) int {
+	b.align(4)
+	pos := len(b.buf)
+	b.buf = binary.LittleEndian.AppendUint32(b.buf, uint32(len(v)))
+	slots := len(b.buf)
+	b.buf = append(b.buf, make([]byte, 4*len(v))...)
+	for i, object := range v {
+		b.patch(slots+4*i, object.encode(b))
+	}
+	return pos
+}
+
+// Encodes a flatbuffer with root as its root table, padded to 8 bytes.
+func fbFinish(root fbObject) []byte {
+	b := &fbBuilder{buf: make([]byte, 4, 512)}
+	b.patch(0, root.encode(b))
+	b.align(8)
+	return b.buf
+}
+
+// Values of the Arrow schema (Schema.fbs and Message.fbs).
+const (
+	arrowMetadataV5        = 4
+	arrowHeaderSchema      = 1
+	arrowHeaderRecordBatch = 3
+
+	arrowTypeInt           = 2
+	arrowTypeFloatingPoint = 3
+	arrowTypeUtf8          = 5
+	arrowTypeBool          = 6
+	arrowTypeTimestamp     = 10
+
+	arrowPrecisionSingle = 1
+	arrowPrecisionDouble = 2
+	arrowMillisecond     = 1
+)
+
+const arrowMagic = "ARROW1"
+
+// A column being buffered, read from the struct field at index.
+type arrowColumn struct {
+	name      string
+	index     int
+	nullable  bool
+	kind      reflect.Kind
+	timestamp bool
+
+	validity []byte // a bit per row, set for non null values
+	nulls    int
+	data     []byte
+	offsets  []byte // int32 offsets into data, for strings
+}
+
+// The column of a struct field, or an error for unsupported types.
+func newArrowColumn(field reflect.StructField, index int) (*arrowColumn, error) {
+	name, options, _ := strings.Cut(field.Tag.Get("arrow"), ",")
+	if name == "" {
+		name = field.Name
+	}
+	column := &arrowColumn{name: name, index: index, timestamp: options == "timestamp"}
+	t := field.Type
+	if t.Kind() == reflect.Pointer {
+		column.nullable = true
+		t = t.Elem()
+	}
+	column.kind = t.Kind()
+	switch column.kind {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint,
+		reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int,
+		reflect.Float32, reflect.Float64, reflect.Bool, reflect.String:
+	default:
+		return nil, fmt.Errorf("arrow column %v: unsupported type %v", name, field.Type)
+	}
+	if column.timestamp && column.kind != reflect.Int64 {
+		return nil, fmt.Errorf("arrow column %v: timestamps must be int64, got %v", name, field.Type)
+	}
+	return column, nil
+}
+
+// The Field table of the column.
+func (c *arrowColumn) field() fbTable {
+	var typeID uint64
+	var typ fbTable
+	switch c.kind {
+	case reflect.Float32:
+		typeID, typ = arrowTypeFloatingPoint, fbTable{fbScalar(2, arrowPrecisionSingle)}
+	case reflect.Float64:
+		typeID, typ = arrowTypeFloatingPoint, fbTable{fbScalar(2, arrowPrecisionDouble)}
+	case reflect.Bool:
+		typeID, typ = arrowTypeBool, fbTable{}
+	case reflect.String:
+		typeID, typ = arrowTypeUtf8, fbTable{}
+	default:
+		if c.timestamp {
+			typeID, typ = arrowTypeTimestamp, fbTable{fbScalar(2, arrowMillisecond), fbRef(fbString("UTC"))}
+			break
+		}
+		signed := uint64(0)
+		if c.kind >= reflect.Int && c.kind <= reflect.Int64 {
+			signed = 1
+		}
+		typ = fbTable{fbScalar(4, uint64(c.width()*8)), fbScalar(1, signed)}
+		typeID = arrowTypeInt
+	}
+	nullable := uint64(0)
+	if c.nullable {
+		nullable = 1
+	}
+	// name | nullable | type_type | type | dictionary | children
+	return fbTable{fbRef(fbString(c.name)), fbScalar(1, nullable), fbScalar(1, typeID), fbRef(typ), fbField{}, fbRef(fbVector{})}
+}
+
+// The width in bytes of the fixed size values of the column, 0 for bools and strings.
+func (c *arrowColumn) width() int {
+	switch c.kind {
+	case reflect.Uint8, reflect.Int8:
+		return 1
+	case reflect.Uint16, reflect.Int16:
+		return 2
+	case reflect.Uint32, reflect.Int32, reflect.Float32:
+		return 4
+	case reflect.Bool, reflect.String:
+		return 0
+	}
+	return 8
+}
+
+func setBit(bits []byte, i int) []byte {
+	for len(bits) <= i/8 {
+		bits = append(bits, 0)
+	}
+	bits[i/8] |= 1 << (i % 8)
+	return bits
+}
+
+// Appends the value of row, the rows th of the batch.
+func (c *arrowColumn) append(row reflect.Value, rows int) {
+	value := row.Field(c.index)
+	valid := !(c.nullable && value.IsNil())
+	if valid {
+		c.validity = setBit(c.validity, rows)
+		if c.nullable {
+			value = value.Elem()
+		}
+	} else {
+		c.nulls++
+		for len(c.validity) <= rows/8 {
+			c.validity = append(c.validity, 0)
+		}
+	}
+
+	switch c.kind {
+	case reflect.Bool:
+		for len(c.data) <= rows/8 {
+			c.data = append(c.data, 0)
+		}
+		if valid && value.Bool() {
+			c.data = setBit(c.data, rows)
+		}
+		return
+	case reflect.String:
+		if len(c.offsets) == 0 {
+			c.offsets = binary.LittleEndian.AppendUint32(c.offsets, 0)
+		}
+		if valid {
+			c.data = append(c.data, value.String()...)
+		}
+		c.offsets = binary.LittleEndian.AppendUint32(c.offsets, uint32(len(c.data)))
+		return
+	}
+
+	var bits uint64
+	if valid {
+		switch {
+		case value.CanUint():
+			bits = value.Uint()
+		case value.CanInt():
+			bits = uint64(value.Int())
+		case c.kind == reflect.Float32:
+			bits = uint64(math.Float32bits(float32(value.Float())))
+		default:
+			bits = math.Float64bits(value.Float())
+		}
+	}
+	switch c.width() {
+	case 1:
+		c.data = append(c.data, byte(bits))
+	case 2:
+		c.data = binary.LittleEndian.AppendUint16(c.data, uint16(bits))
+	case 4:
+		c.data = binary.LittleEndian.AppendUint32(c.data, uint32(bits))
+	default:
+		c.data = binary.LittleEndian.AppendUint64(c.data, bits)
+	}
+}
+
+// The buffers of the column, the validity bitmap being empty when there are no nulls.
+func (c *arrowColumn) buffers() [][]byte {
+	validity := c.validity
+	if c.nulls == 0 {
+		validity = nil
+	}
+	if c.kind == reflect.String {
+		return [][]byte{validity, c.offsets, c.data}
+	}
+	return [][]byte{validity, c.data}
+}
+
+func (c *arrowColumn) reset() {
+	c.validity, c.data, c.offsets = c.validity[:0], c.data[:0], c.offsets[:0]
+	c.nulls = 0
+}
+
+/*
+Writes rows of R in the Arrow IPC file format, or in the stream format when stream is
+set. Close must be called to write the last batch, and the footer of the file format.
+*/
+type ArrowWriter[R any] struct {
+	out     io.Writer
+	stream  bool
+	columns []*arrowColumn
+	rows    int
+	written int64
+	blocks  []byte // the footer Block structs of the record batches
+	batches int
+	err     error
+}
+
+func NewArrowWriter[R any](out io.Writer, stream bool) (*ArrowWriter[R], error) {
+	t := reflect.TypeFor[R]()
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("arrow rows must be structs, got %v", t)
+	}
+	w := &ArrowWriter[R]{out: out, stream: stream}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("arrow") == "-" {
+			continue
+		}
+		column, err := newArrowColumn(field, i)
+		if err != nil {
+			return nil, err
+		}
+		w.columns = append(w.columns, column)
+	}
+
+	if !stream {
+		w.write([]byte(arrowMagic + "\x00\x00"))
+	}
+	w.writeMessage(arrowHeaderSchema, w.schema(), nil)
+	return w, w.err
+}
+
+// The Schema table of the rows.
+func (w *ArrowWriter[R]) schema() fbTable {
+	fields := make(fbVector, len(w.columns))
+	for i, column := range w.columns {
+		fields[i] = column.field()
+	}
+	// endianness (little) | fields
+	return fbTable{fbScalar(2, 0), fbRef(fields)}
+}
+
+// Writes data, keeping the first error.
+func (w *ArrowWriter[R]) write(data []byte) {
+	if w.err != nil {
+		return
+	}
+	var n int
+	n, w.err = w.out.Write(data)
+	w.written += int64(n)
+}
+
+// Writes an encapsulated message, of a header and a body of buffers each padded to 8 bytes.
+func (w *ArrowWriter[R]) writeMessage(headerType uint64, header fbTable, body [][]byte) {
+	bodyLength := 0
+	for _, buffer := range body {
+		bodyLength += (len(buffer) + 7) &^ 7
+	}
+	// version | header_type | header | bodyLength
+	metadata := fbFinish(fbTable{fbScalar(2, arrowMetadataV5), fbScalar(1, headerType), fbRef(header), fbScalar(8, uint64(bodyLength))})
+
+	offset := w.written
+	prefix := binary.LittleEndian.AppendUint32(nil, 0xFFFFFFFF)
+	prefix = binary.LittleEndian.AppendUint32(prefix, uint32(len(metadata)))
+	w.write(prefix)
+	w.write(metadata)
+	var padding [8]byte
+	for _, buffer := range body {
+		w.write(buffer)
+		w.write(padding[:(8-len(buffer)%8)%8])
+	}
+
+	if headerType == arrowHeaderRecordBatch {
+		// offset | metaDataLength | padding | bodyLength
+		w.blocks = binary.LittleEndian.AppendUint64(w.blocks, uint64(offset))
+		w.blocks = binary.LittleEndian.AppendUint32(w.blocks, uint32(len(prefix)+len(metadata)))
+		w.blocks = binary.LittleEndian.AppendUint32(w.blocks, 0)
+		w.blocks = binary.LittleEndian.AppendUint64(w.blocks, uint64(bodyLength))
+		w.batches++
+	}
+}
+
+func (w *ArrowWriter[R]) Write(row R) error {
+	value := reflect.ValueOf(row)
+	for _, column := range w.columns {
+		column.append(value, w.rows)
+	}
+	w.rows++
+	if w.rows == arrowBatch {
+		w.flush()
+	}
+	return w.err
+}
+
+// Writes the buffered rows as a record batch.
+func (w *ArrowWriter[R]) flush() {
+	if w.rows == 0 {
+		return
+	}
+	var nodes, buffers []byte
+	var body [][]byte
+	offset := 0
+	for _, column := range w.columns {
+		// length | null_count
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(w.rows))
+		nodes = binary.LittleEndian.AppendUint64(nodes, uint64(column.nulls))
+		for _, buffer := range column.buffers() {
+			// offset | length
+			buffers = binary.LittleEndian.AppendUint64(buffers, uint64(offset))
+			buffers = binary.LittleEndian.AppendUint64(buffers, uint64(len(buffer)))
+			offset += (len(buffer) + 7) &^ 7
+			body = append(body, buffer)
+		}
+	}
+	// length | nodes | buffers
+	header := fbTable{fbScalar(8, uint64(w.rows)), fbRef(fbStructs{len(nodes) / 16, nodes}), fbRef(fbStructs{len(buffers) / 16, buffers})}
+	w.writeMessage(arrowHeaderRecordBatch, header, body)
+
+	for _, column := range w.columns {
+		column.reset()
+	}
+	w.rows = 0
+}
+
+// Writes the buffered rows, the end of stream marker, and the footer of the file format.
+func (w *ArrowWriter[R]) Close() error {
+	w.flush()
+	w.write(binary.LittleEndian.AppendUint64(nil, 0x00000000FFFFFFFF))
+	if !w.stream {
+		// version | schema | dictionaries | recordBatches
+		footer := fbFinish(fbTable{fbScalar(2, arrowMetadataV5), fbRef(w.schema()), fbField{}, fbRef(fbStructs{w.batches, w.blocks})})
+		w.write(footer)
+		w.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+		w.write([]byte(arrowMagic))
+	}
+	return w.err
+}
+
+// An ArrowWriter into a file, see CreateArrowFile.
+type ArrowFile[R any] struct {
+	*ArrowWriter[R]
+	sink *Sink
+}
+
+/*
+Creates an Arrow file of rows of R, in the stream format for .arrows files and in the
+file format otherwise. the file goes through a sink, so it may be compressed by extension.
+*/
+func CreateArrowFile[R any](filename string) (*ArrowFile[R], error) {
+	sink, err := CreateSink(filename)
+	if err != nil {
+		return nil, err
+	}
+	stream := filepath.Ext(strings.TrimSuffix(filename, compressionSuffix(filename))) == ".arrows"
+	writer, err := NewArrowWriter[R](sink, stream)
+	if err != nil {
+		sink.Close()
+		return nil, err
+	}
+	return &ArrowFile[R]{ArrowWriter: writer, sink: sink}, nil
+}
+
+// Writes the end of the Arrow file, and closes the sink.
+func (f *ArrowFile[R]) Close() error {
+	if err := f.ArrowWriter.Close(); err != nil {
+		f.sink.Close()
+		return err
+	}
+	return f.sink.Close()
+}
@@ -0,0 +1,216 @@
+package graph
+
+/*
+Implements an immutable compressed sparse row (CSR) graph.
+
+The nested maps of Graph cost tens of bytes per edge, CSR holds the sorted source nodes,
+an offset per node into a single neighbor array, and an optional parallel weight array,
+so an edge costs the size of T (and W). Lookups use binary search.
+*/
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+)
+
+type CSR[T Integer, W any] struct {
+	nodes     []T      // sorted source nodes
+	offsets   []uint64 // neighbors of nodes[i] are neighbors[offsets[i]:offsets[i+1]]
+	neighbors []T      // sorted per node
+	weights   []W      // parallel to neighbors, nil for unweighted graphs
+}
+
+/*
+Builds a CSR from a graph. weights are kept only if weighted is set, so unweighted
+graphs (Graph[T, struct{}]) do not pay for them.
+*/
+func NewCSR[T Integer, U any](graph Graph[T, U], weighted bool) *CSR[T, U] {
+	edges := 0
+	nodes := make([]T, 0, len(graph))
+	for node, neighbors := range graph {
+		nodes = append(nodes, node)
+		edges += len(neighbors)
+	}
+	slices.Sort(nodes)
+
+	csr := &CSR[T, U]{
+		nodes:     nodes,
+		offsets:   make([]uint64, len(nodes)+1),
+		neighbors: make([]T, 0, edges),
+	}
+	if weighted {
+		csr.weights = make([]U, 0, edges)
+	}
+	for i, node := range nodes {
+		start := len(csr.neighbors)
+		for neighbor := range graph[node] {
+			csr.neighbors = append(csr.neighbors, neighbor)
+		}
+		row := csr.neighbors[start:]
+		slices.Sort(row)
+		if weighted {
+			for _, neighbor := range row {
+				csr.weights = append(csr.weights, graph[node][neighbor])
+			}
+		}
+		csr.offsets[i+1] = uint64(len(csr.neighbors))
+	}
+	return csr
+}
+
+type csrEdge[T Integer, W any] struct {
+	src, dst T
+	weight   W
+}
+
+/*
+Builds a CSR from a stream of edges, for example while reading a file, without
+building the map form first. when an edge is added more than once the last weight is kept.
+*/
+type CSRBuilder[T Integer, W any] struct {
+	edges    []csrEdge[T, W]
+	weighted bool
+}
+
+func NewCSRBuilder[T Integer, W any](weighted bool) *CSRBuilder[T, W] {
+	return &CSRBuilder[T, W]{weighted: weighted}
+}
+
+func (b *CSRBuilder[T, W]) AddEdge(src, dst T, weight W) {
+	b.edges = append(b.edges, csrEdge[T, W]{src, dst, weight})
+}
+
+// Builds the CSR, the builder should not be used afterwards.
+func (b *CSRBuilder[T, W]) Build() *CSR[T, W] {
+	slices.SortStableFunc(b.edges, func(x, y csrEdge[T, W]) int {
+		if c := cmp.Compare(x.src, y.src); c != 0 {
+			return c
+		}
+		return cmp.Compare(x.dst, y.dst)
+	})
+
+	csr := &CSR[T, W]{
+		offsets:   []uint64{0},
+		neighbors: make([]T, 0, len(b.edges)),
+	}
+	if b.weighted {
+		csr.weights = make([]W, 0, len(b.edges))
+	}
+	for i, edge := range b.edges {
+		if i+1 < len(b.edges) && b.edges[i+1].src == edge.src && b.edges[i+1].dst == edge.dst {
+			continue // a later duplicate wins
+		}
+		if len(csr.nodes) == 0 || csr.nodes[len(csr.nodes)-1] != edge.src {
+			if len(csr.nodes) > 0 {
+				csr.offsets = append(csr.offsets, uint64(len(csr.neighbors)))
+			}
+			csr.nodes = append(csr.nodes, edge.src)
+		}
+		csr.neighbors = append(csr.neighbors, edge.dst)
+		if b.weighted {
+			csr.weights = append(csr.weights, edge.weight)
+		}
+	}
+	if len(csr.nodes) > 0 {
+		csr.offsets = append(csr.offsets, uint64(len(csr.neighbors)))
+	}
+	b.edges = nil
+	return csr
+}
+
+func (c *CSR[T, W]) NumNodes() int {
+	return len(c.nodes)
+}
+
+func (c *CSR[T, W]) NumEdges() int {
+	return len(c.neighbors)
+}
+
+func (c *CSR[T, W]) Weighted() bool {
+	return c.weights != nil
+}
+
+// The sorted source nodes, the slice must not be modified.
+func (c *CSR[T, W]) Nodes() []T {
+	return c.nodes
+}
+
+func (c *CSR[T, W]) index(node T) (int, bool) {
+	i := sort.Search(len(c.nodes), func(i int) bool { return c.nodes[i] >= node })
+	return i, i < len(c.nodes) && c.nodes[i] == node
+}
+
+// The sorted neighbors of node, the slice must not be modified.
+func (c *CSR[T, W]) Neighbors(node T) []T {
+	i, ok := c.index(node)
+	if !ok {
+		return nil
+	}
+	return c.neighbors[c.offsets[i]:c.offsets[i+1]]
+}
+
+// The weights parallel to Neighbors(node), nil for unweighted graphs.
+func (c *CSR[T, W]) Weights(node T) []W {
+	i, ok := c.index(node)
+	if !ok || c.weights == nil {
+		return nil
+	}
+	return c.weights[c.offsets[i]:c.offsets[i+1]]
+}
+
+func (c *CSR[T, W]) Degree(node T) int {
+	return len(c.Neighbors(node))
+}
+
+func (c *CSR[T, W]) HasEdge(src, dst T) bool {
+	_, ok := c.edgeIndex(src, dst)
+	return ok
+}
+
+// The weight of the edge src -> dst. the zero weight is returned for unweighted graphs.
+func (c *CSR[T, W]) Weight(src, dst T) (W, bool) {
+	var zero W
+	j, ok := c.edgeIndex(src, dst)
+	if !ok || c.weights == nil {
+		return zero, ok
+	}
+	return c.weights[j], true
+}
+
+func (c *CSR[T, W]) edgeIndex(src, dst T) (int, bool) {
+	i, ok := c.index(src)
+	if !ok {
+		return 0, false
+	}
+	start, end := int(c.offsets[i]), int(c.offsets[i+1])
+	row := c.neighbors[start:end]
+	j := sort.Search(len(row), func(j int) bool { return row[j] >= dst })
+	return start + j, j < len(row) && row[j] == dst
+}
+
+// Calls f for every edge, ordered by src then dst.
+func (c *CSR[T, W]) ForEachEdge(f func(src, dst T, weight W)) {
+	var zero W
+	for i, node := range c.nodes {
+		for j := c.offsets[i]; j < c.offsets[i+1]; j++ {
+			weight := zero
+			if c.weights != nil {
+				weight = c.weights[j]
+			}
+			f(node, c.neighbors[j], weight)
+		}
+	}
+}
+
+// Converts back to the map form.
+func (c *CSR[T, W]) ToGraph() Graph[T, W] {
+	graph := make(Graph[T, W], len(c.nodes))
+	for i, node := range c.nodes {
+		graph[node] = make(map[T]W, c.offsets[i+1]-c.offsets[i])
+	}
+	c.ForEachEdge(func(src, dst T, weight W) {
+		graph[src][dst] = weight
+	})
+	return graph
+}
@@ -0,0 +1,183 @@
+package graph
+
+/*
+Implements reading edge lists, as written by EdgeListOutputGraph and the likes.
+
+Each line is src target [weight ...], columns after the weight are ignored, so that
+unweighted outputs (whose weight column is "{}") read as Graph[T, struct{}].
+Input may be gzip or zstd compressed, which is detected from the content and not the file name.
+*/
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// How ReadEdgeList splits and skips lines. the zero value splits on whitespace and skips nothing.
+type EdgeListOptions struct {
+	Delimiter  string // column separator, empty for any whitespace
+	Comment    string // lines starting with it are skipped, empty for no comments
+	HeaderRows int    // number of leading (non comment) lines to skip
+}
+
+// Options reading the files written by this package, and most text edge lists (SNAP and the likes).
+func DefaultEdgeListOptions() EdgeListOptions {
+	return EdgeListOptions{Comment: "#"}
+}
+
+/*
+Reads an edge list file into a graph. U is the weight type, struct{} ignores the weight column,
+and any other type is parsed from the third column. ids of files written by
+EdgeListOutputGraphRemapped are restored.
+*/
+func ReadEdgeList[T Integer, U any](filename string, options EdgeListOptions) (Graph[T, U], error) {
+	var graph Graph[T, U]
+	err := readFile(filename, func(r io.Reader) (err error) {
+		graph, err = readEdges[T, U](r, options)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restoreIds(filename, graph)
+}
+
+// Reads an edge list from reader into a graph, see ReadEdgeList. gzip and zstd content is decompressed.
+func ReadEdges[T Integer, U any](reader io.Reader, options EdgeListOptions) (Graph[T, U], error) {
+	decompressed, err := Decompress(reader)
+	if err != nil {
+		return nil, fmt.Errorf("decompression error: %v", err)
+	}
+	defer decompressed.Close()
+	return readEdges[T, U](decompressed, options)
+}
+
+// The implementation of ReadEdges, over decompressed content.
+func readEdges[T Integer, U any](reader io.Reader, options EdgeListOptions) (Graph[T, U], error) {
+	graph := make(Graph[T, U])
+	err := scanEdges(reader, options, func(src, target T, weight U) error {
+		neighbors, ok := graph[src]
+		if !ok {
+			neighbors = make(map[T]U)
+			graph[src] = neighbors
+		}
+		neighbors[target] = weight
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return graph, nil
+}
+
+// Same as ReadEdgeList, but streams every edge into fn instead of building a graph.
+func ReadEdgeListFunc[T Integer, U any](filename string, options EdgeListOptions, fn func(src, target T, weight U) error) error {
+	return readFile(filename, func(r io.Reader) error {
+		return scanEdges(r, options, fn)
+	})
+}
+
+/*
+Streams the edges of an edge list read from reader into fn, stopping at the first
+malformed line or error returned by fn. gzip and zstd content is decompressed.
+*/
+func ScanEdgeList[T Integer, U any](reader io.Reader, options EdgeListOptions, fn func(src, target T, weight U) error) error {
+	decompressed, err := Decompress(reader)
+	if err != nil {
+		return fmt.Errorf("decompression error: %v", err)
+	}
+	defer decompressed.Close()
+	return scanEdges(decompressed, options, fn)
+}
+
+// The implementation of ScanEdgeList, over decompressed content.
+func scanEdges[T Integer, U any](reader io.Reader, options EdgeListOptions, fn func(src, target T, weight U) error) error {
+	buffered := BufferedReader(reader)
+
+	_, unweighted := any(*new(U)).(struct{})
+	headers := options.HeaderRows
+	lineNumber := 0
+	for {
+		line, err := buffered.ReadString('\n')
+		if len(line) == 0 && err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		lineNumber++
+
+		line = strings.TrimSpace(line)
+		if line == "" || (options.Comment != "" && strings.HasPrefix(line, options.Comment)) {
+			continue
+		}
+		if headers > 0 {
+			headers--
+			continue
+		}
+
+		var fields []string
+		if options.Delimiter == "" {
+			fields = strings.Fields(line)
+		} else {
+			fields = strings.Split(line, options.Delimiter)
+			for i := range fields {
+				fields[i] = strings.TrimSpace(fields[i])
+			}
+		}
+		if len(fields) < 2 {
+			return fmt.Errorf("line %d: expected src target, got %q", lineNumber, line)
+		}
+		if !unweighted && len(fields) < 3 {
+			return fmt.Errorf("line %d: expected src target weight, got %q", lineNumber, line)
+		}
+
+		src, err := parseInteger[T](fields[0])
+		if err != nil {
+			return fmt.Errorf("line %d: bad src: %w", lineNumber, err)
+		}
+		target, err := parseInteger[T](fields[1])
+		if err != nil {
+			return fmt.Errorf("line %d: bad target: %w", lineNumber, err)
+		}
+		var weight U
+		if !unweighted {
+			if err := parseWeight(fields[2], &weight); err != nil {
+				return fmt.Errorf("line %d: bad weight: %w", lineNumber, err)
+			}
+		}
+		if err := fn(src, target, weight); err != nil {
+			return err
+		}
+	}
+}
+
+// Parses a weight column, with fast paths for the common weight types.
+func parseWeight[U any](s string, weight *U) error {
+	var err error
+	switch w := any(weight).(type) {
+	case *uint32:
+		var v uint64
+		v, err = strconv.ParseUint(s, 10, 32)
+		*w = uint32(v)
+	case *uint64:
+		*w, err = strconv.ParseUint(s, 10, 64)
+	case *int:
+		*w, err = strconv.Atoi(s)
+	case *int64:
+		*w, err = strconv.ParseInt(s, 10, 64)
+	case *float64:
+		*w, err = strconv.ParseFloat(s, 64)
+	case *float32:
+		var v float64
+		v, err = strconv.ParseFloat(s, 32)
+		*w = float32(v)
+	case *string:
+		*w = s
+	default:
+		_, err = fmt.Sscan(s, weight)
+	}
+	return err
+}
@@ -1,15 +1,14 @@
 package graph
 
 import (
-	"os"
 	"fmt"
 	"time"
 	"log"
-	"bufio"
 	"io"
 	"strings"
 	"strconv"
 	"encoding/binary"
+	"os"
 )
 
 /*
@@ -23,6 +22,22 @@ type Integer interface {
 
 type Graph[T comparable,U any] map[T]map[T]U
 
+// Weights that can be written in binary formats.
+type Number interface {
+	Integer | ~float32 | ~float64
+}
+
+// Gives a node a readable name for output, for example a login from a dictionary.
+type Labeler[T any] func(T) string
+
+/*
+An edge value holding several relations between the same two nodes, making the graph
+multi relational. for example counts of each event type between a user and a repo.
+*/
+type Relational[W any] interface {
+	Relations(yield func(relation string, weight W))
+}
+
 
 const logEvery = 100000
 
@@ -32,109 +47,267 @@ Outputs a graph to file in the classic edge-list format. this is the most recomm
 
 Note that for weighted graphs we will have src edge weight. and in nonwieghted will have trailing spaces.
 */
-func EdgeListOutputGraph[T comparable, U any](outputFile string, graph Graph[T, U]) {
-	file, err := os.OpenFile(outputFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error opening %v: %v\n", outputFile, err)
-		return
-	}
-	defer file.Close()
+func EdgeListOutputGraph[T comparable, U any](outputFile string, graph Graph[T, U]) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return WriteEdgeList(w, graph)
+	})
+}
 
+// Writes a graph in the format of EdgeListOutputGraph.
+func WriteEdgeList[T comparable, U any](w io.Writer, graph Graph[T, U]) error {
+	writer := BufferedWriter(w)
 	for src, neighbors := range graph {
 		for target, weight := range neighbors {
-			_, err := fmt.Fprintf(file, "%v %v %v\n", src, target, weight)
+			if _, err := fmt.Fprintf(writer, "%v %v %v\n", src, target, weight); err != nil {
+				return err
+			}
+		}
+	}
+	return writer.Flush()
+}
+
+/*
+Outputs a multi relational graph in the edge-list format with a relation column,
+src target relation weight, writing a line for every relation of every edge.
+*/
+func RelationEdgeListOutputGraph[T comparable, W any, U Relational[W]](outputFile string, graph Graph[T, U]) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return WriteRelationEdgeList[T, W](w, graph)
+	})
+}
+
+// Writes a multi relational graph in the format of RelationEdgeListOutputGraph.
+func WriteRelationEdgeList[T comparable, W any, U Relational[W]](w io.Writer, graph Graph[T, U]) error {
+	writer := BufferedWriter(w)
+	var err error
+	for src, neighbors := range graph {
+		for target, relations := range neighbors {
+			relations.Relations(func(relation string, weight W) {
+				if err == nil {
+					_, err = fmt.Fprintf(writer, "%v %v %v %v\n", src, target, relation, weight)
+				}
+			})
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error writing edge: %v\n", err)
+				return err
+			}
+		}
+	}
+	return writer.Flush()
+}
+
+/*
+Outputs graphs partitioned by a key (for example a graph per repo) into a single
+edge-list file with a leading key column, key src target weight.
+*/
+func PartitionedEdgeListOutputGraph[K comparable, T comparable, U any](outputFile string, graphs map[K]Graph[T, U]) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return WritePartitionedEdgeList(w, graphs)
+	})
+}
+
+// Writes partitioned graphs in the format of PartitionedEdgeListOutputGraph.
+func WritePartitionedEdgeList[K comparable, T comparable, U any](w io.Writer, graphs map[K]Graph[T, U]) error {
+	writer := BufferedWriter(w)
+	for key, graph := range graphs {
+		for src, neighbors := range graph {
+			for target, weight := range neighbors {
+				if _, err := fmt.Fprintf(writer, "%v %v %v %v\n", key, src, target, weight); err != nil {
+					return err
+				}
 			}
 		}
 	}
+	return writer.Flush()
+}
+
+/*
+Outputs multi relational graphs partitioned by a key into a single edge-list file, in the
+format of RelationEdgeListOutputGraph with a leading key column, key src target relation weight.
+*/
+func PartitionedRelationEdgeListOutputGraph[K comparable, T comparable, W any, U Relational[W]](outputFile string, graphs map[K]Graph[T, U]) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return WritePartitionedRelationEdgeList[K, T, W](w, graphs)
+	})
+}
+
+// Writes partitioned multi relational graphs in the format of PartitionedRelationEdgeListOutputGraph.
+func WritePartitionedRelationEdgeList[K comparable, T comparable, W any, U Relational[W]](w io.Writer, graphs map[K]Graph[T, U]) error {
+	writer := BufferedWriter(w)
+	var err error
+	for key, graph := range graphs {
+		for src, neighbors := range graph {
+			for target, relations := range neighbors {
+				relations.Relations(func(relation string, weight W) {
+					if err == nil {
+						_, err = fmt.Fprintf(writer, "%v %v %v %v %v\n", key, src, target, relation, weight)
+					}
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return writer.Flush()
 }
 
 /*
 Outputs a graph in the format of Vertex Neighbor Neighbor... seperated by newline.
 */
-func NeighborOutputGraph[T comparable,U any](outputFile string, graph Graph[T,U]) {
-	file, err := os.OpenFile(outputFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error encountered in opening %v: %v\n", outputFile, err);
+func NeighborOutputGraph[T comparable,U any](outputFile string, graph Graph[T,U]) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return WriteNeighbors(w, graph)
+	})
+}
+
+// Writes a graph in the format of NeighborOutputGraph.
+func WriteNeighbors[T comparable, U any](w io.Writer, graph Graph[T, U]) error {
+	writer := BufferedWriter(w)
+	for user, neighbors := range graph {
+		if _, err := fmt.Fprintf(writer, "%v", user); err != nil {
+			return err
+		}
+		for repo := range neighbors {
+			if _, err := fmt.Fprintf(writer, " %v", repo); err != nil {
+				return err
+			}
+		}
+		if err := writer.WriteByte('\n'); err != nil {
+			return err
+		}
 	}
-	defer file.Close()
+	return writer.Flush()
+}
 
-	for user := range graph {
-		_, err = fmt.Fprintf(file, fmt.Sprintf("%v", user))
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error encountered Fprintf %v\n", err);
-		}	
-		for repo := range graph[user] {
-			// Write string to file
-			_, err = fmt.Fprintf(file, fmt.Sprintf(" %v", repo))
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error encountered Fprintf %v\n", err);
-			}	
+/*
+Same as EdgeListOutputGraph, but nodes are written by their labels instead of their values.
+sources are labeled by srcLabel and targets by targetLabel, as in bipartite graphs they differ (user -> repo).
+*/
+func LabeledEdgeListOutputGraph[T comparable, U any](outputFile string, graph Graph[T, U], srcLabel, targetLabel Labeler[T]) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return WriteLabeledEdgeList(w, graph, srcLabel, targetLabel)
+	})
+}
+
+// Writes a graph in the format of LabeledEdgeListOutputGraph.
+func WriteLabeledEdgeList[T comparable, U any](w io.Writer, graph Graph[T, U], srcLabel, targetLabel Labeler[T]) error {
+	writer := BufferedWriter(w)
+	for src, neighbors := range graph {
+		srcName := srcLabel(src)
+		for target, weight := range neighbors {
+			if _, err := fmt.Fprintf(writer, "%v %v %v\n", srcName, targetLabel(target), weight); err != nil {
+				return err
+			}
 		}
-		_, err = fmt.Fprintln(file, "")
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error encountered Fprintf %v\n", err);
-		}	
 	}
+	return writer.Flush()
+}
+
+// Same as NeighborOutputGraph, but nodes are written by their labels, see LabeledEdgeListOutputGraph.
+func LabeledNeighborOutputGraph[T comparable, U any](outputFile string, graph Graph[T, U], srcLabel, targetLabel Labeler[T]) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return WriteLabeledNeighbors(w, graph, srcLabel, targetLabel)
+	})
+}
+
+// Writes a graph in the format of LabeledNeighborOutputGraph.
+func WriteLabeledNeighbors[T comparable, U any](w io.Writer, graph Graph[T, U], srcLabel, targetLabel Labeler[T]) error {
+	writer := BufferedWriter(w)
+	for src, neighbors := range graph {
+		writer.WriteString(srcLabel(src))
+		for target := range neighbors {
+			writer.WriteByte(' ')
+			writer.WriteString(targetLabel(target))
+		}
+		if err := writer.WriteByte('\n'); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
 }
 
 /*
 Read graph of (raw test) format. Node Neighbor Neighbor ... 
 Each node is seperated by newline and each Neighbor by space.
+ids of files written by NeighborOutputGraphRemapped are restored.
 */
-func ReadNeighborGraph[T Integer](filename string) Graph[T, struct{}] {
-	file, err := os.Open(filename)
+func ReadNeighborGraph[T Integer](filename string) (Graph[T, struct{}], error) {
+	file, err := openSource(filename)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error encountered %v\n", err);
+		return nil, err
 	}
 	defer file.Close()
 
+	// counting the lines of a compressed file would decompress it twice, just for the ETA
+	totalCount := 0
+	if (!file.compressed()) {
+		totalCount, _ = countLines(filename)
+	}
+	graph, err := readNeighbors[T](file.Reader, totalCount)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", filename, err)
+	}
+	return restoreIds(filename, graph)
+}
+
+// Reads a graph in the format of ReadNeighborGraph. malformed nodes and neighbors are skipped.
+func ReadNeighbors[T Integer](r io.Reader) (Graph[T, struct{}], error) {
+	return readNeighbors[T](r, 0)
+}
+
+// The implementation of ReadNeighbors, logging the progress against totalCount lines when known.
+func readNeighbors[T Integer](r io.Reader, totalCount int) (Graph[T, struct{}], error) {
 	userProjects := make(Graph[T, struct{}])
-    reader := bufio.NewReader(file)
+	reader := BufferedReader(r)
 
 	start := time.Now()
 	processed := 0
-	totalCount, _ := countLines(filename)	
 
-    for {
-        line, err := reader.ReadString('\n')
-        if len(line) == 0 && err != nil {
-            break // EOF or error
-        }
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) == 0 && err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
 
 		processed++
 
-        line = strings.TrimSpace(line)
-        if line == "" {
-            continue
-        }
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
 
-        tokens := strings.Fields(line)
-        userID, err := parseInteger[T](tokens[0])
-        if err != nil {
-            continue // skip malformed user ID
-        }
+		tokens := strings.Fields(line)
+		userID, err := parseInteger[T](tokens[0])
+		if err != nil {
+			continue // skip malformed user ID
+		}
 
-        if _, ok := userProjects[userID]; !ok {
-            userProjects[userID] = make(map[T]struct{})
-        }
+		if _, ok := userProjects[userID]; !ok {
+			userProjects[userID] = make(map[T]struct{})
+		}
 
-        for _, t := range tokens[1:] {
-            projID, err := parseInteger[T](t)
-            if err == nil {
-                userProjects[userID][projID] = struct{}{}
-            }
-        }
+		for _, t := range tokens[1:] {
+			projID, err := parseInteger[T](t)
+			if err == nil {
+				userProjects[userID][projID] = struct{}{}
+			}
+		}
 		if (processed % logEvery == 0) {
+			if totalCount == 0 {
+				log.Printf("ReadNeighborGraph Progress: %d lines\n", processed)
+				continue
+			}
 			elapsed := time.Since(start)
 			remaining := totalCount - processed
 			rate := float64(processed) / elapsed.Seconds()
 			eta := time.Duration(float64(remaining)/rate) * time.Second
 			log.Printf("ReadNeighborGraph Progress: %d/%d | ETA: %s\n", processed, totalCount, eta.Truncate(time.Second))
-		}	
+		}
 	}
-    return userProjects
+	return userProjects, nil
 }
 
 /*
@@ -164,6 +337,7 @@ func parseInteger[T Integer](s string) (T, error) {
 	return T(i), nil
 }
 
+// Counts the lines of an uncompressed file.
 func countLines(filename string) (int, error) {
     file, err := os.Open(filename)
     if err != nil {
@@ -201,11 +375,14 @@ func countLines(filename string) (int, error) {
 Save grpah in format node | deg | neighbor | neighbor ... | node | deg | negihbor ...
 */
 func WriteNeighborGraphBinary[T Integer](filename string, graph map[T]map[T]struct{}) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
+	return writeFile(filename, func(w io.Writer) error {
+		return WriteNeighborsBinary(w, graph)
+	})
+}
+
+// Writes a graph in the format of WriteNeighborGraphBinary.
+func WriteNeighborsBinary[T Integer](w io.Writer, graph map[T]map[T]struct{}) error {
+	writer := BufferedWriter(w)
 
 	processed := 0
 	totalCount := len(graph)
@@ -215,18 +392,18 @@ func WriteNeighborGraphBinary[T Integer](filename string, graph map[T]map[T]stru
 		degree := T(len(neighbors))
 
 		// Write node
-		if err := binary.Write(file, binary.LittleEndian, node); err != nil {
+		if err := binary.Write(writer, binary.LittleEndian, node); err != nil {
 			return err
 		}
 
 		// Write degree
-		if err := binary.Write(file, binary.LittleEndian, degree); err != nil {
+		if err := binary.Write(writer, binary.LittleEndian, degree); err != nil {
 			return err
 		}
 
 		// Write neighbors
 		for neighbor := range neighbors {
-			if err := binary.Write(file, binary.LittleEndian, neighbor); err != nil {
+			if err := binary.Write(writer, binary.LittleEndian, neighbor); err != nil {
 				return err
 			}
 		}
@@ -238,31 +415,39 @@ func WriteNeighborGraphBinary[T Integer](filename string, graph map[T]map[T]stru
 			rate := float64(processed) / elapsed.Seconds()
 			eta := time.Duration(float64(remaining)/rate) * time.Second
 			log.Printf("WriteNeighborGraphBinary Progress: %d/%d | ETA: %s\n", processed, totalCount, eta.Truncate(time.Second))
-		}	
+		}
 	}
 
-	return nil
+	return writer.Flush()
 }
 
-//TEST
+// Reads a graph written by WriteNeighborGraphBinary.
 func ReadNeighborGraphBinary[T Integer](filename string) (map[T]map[T]struct{}, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
+	var graph map[T]map[T]struct{}
+	err := readFile(filename, func(r io.Reader) (err error) {
+		graph, err = ReadNeighborsBinary[T](r)
+		return err
+	})
+	return graph, err
+}
 
+// Reads a graph in the format of WriteNeighborGraphBinary.
+func ReadNeighborsBinary[T Integer](r io.Reader) (map[T]map[T]struct{}, error) {
+	reader := BufferedReader(r)
 	graph := make(map[T]map[T]struct{})
 	var node, degree T
 
 	for {
 		// Read node
-		if err := binary.Read(file, binary.LittleEndian, &node); err != nil {
-			break // likely EOF
+		if err := binary.Read(reader, binary.LittleEndian, &node); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
 		}
 
 		// Read degree
-		if err := binary.Read(file, binary.LittleEndian, &degree); err != nil {
+		if err := binary.Read(reader, binary.LittleEndian, &degree); err != nil {
 			return nil, err
 		}
 
@@ -271,7 +456,7 @@ func ReadNeighborGraphBinary[T Integer](filename string) (map[T]map[T]struct{},
 		// Read neighbors
 		var neighbor T
 		for i := T(0); i < degree; i++ {
-			if err := binary.Read(file, binary.LittleEndian, &neighbor); err != nil {
+			if err := binary.Read(reader, binary.LittleEndian, &neighbor); err != nil {
 				return nil, err
 			}
 			neighbors[neighbor] = struct{}{}
@@ -282,3 +467,97 @@ func ReadNeighborGraphBinary[T Integer](filename string) (map[T]map[T]struct{},
 
 	return graph, nil
 }
+
+/*
+Save a weighted graph in format node | deg | neighbor | weight | neighbor | weight ... | node | deg ...
+the node and degree have the size of T, and each weight the size of W.
+*/
+func WriteWeightedNeighborGraphBinary[T Integer, W Number](filename string, graph Graph[T, W]) error {
+	return writeFile(filename, func(w io.Writer) error {
+		return WriteWeightedNeighborsBinary(w, graph)
+	})
+}
+
+// Writes a weighted graph in the format of WriteWeightedNeighborGraphBinary.
+func WriteWeightedNeighborsBinary[T Integer, W Number](w io.Writer, graph Graph[T, W]) error {
+	writer := BufferedWriter(w)
+
+	processed := 0
+	totalCount := len(graph)
+	start := time.Now()
+
+	for node, neighbors := range graph {
+		if err := binary.Write(writer, binary.LittleEndian, node); err != nil {
+			return err
+		}
+		if err := binary.Write(writer, binary.LittleEndian, T(len(neighbors))); err != nil {
+			return err
+		}
+		for neighbor, weight := range neighbors {
+			if err := binary.Write(writer, binary.LittleEndian, neighbor); err != nil {
+				return err
+			}
+			if err := binary.Write(writer, binary.LittleEndian, weight); err != nil {
+				return err
+			}
+		}
+
+		processed++
+		if (processed % logEvery == 0) {
+			elapsed := time.Since(start)
+			remaining := totalCount - processed
+			rate := float64(processed) / elapsed.Seconds()
+			eta := time.Duration(float64(remaining)/rate) * time.Second
+			log.Printf("WriteWeightedNeighborGraphBinary Progress: %d/%d | ETA: %s\n", processed, totalCount, eta.Truncate(time.Second))
+		}
+	}
+
+	return writer.Flush()
+}
+
+/*
+Reads a graph saved by WriteWeightedNeighborGraphBinary. T and W must be the
+types the graph was written with, as the format holds no header.
+*/
+func ReadWeightedNeighborGraphBinary[T Integer, W Number](filename string) (Graph[T, W], error) {
+	var graph Graph[T, W]
+	err := readFile(filename, func(r io.Reader) (err error) {
+		graph, err = ReadWeightedNeighborsBinary[T, W](r)
+		return err
+	})
+	return graph, err
+}
+
+// Reads a weighted graph in the format of WriteWeightedNeighborGraphBinary.
+func ReadWeightedNeighborsBinary[T Integer, W Number](r io.Reader) (Graph[T, W], error) {
+	reader := BufferedReader(r)
+	graph := make(Graph[T, W])
+	var node, degree, neighbor T
+	var weight W
+
+	for {
+		if err := binary.Read(reader, binary.LittleEndian, &node); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &degree); err != nil {
+			return nil, err
+		}
+
+		neighbors := make(map[T]W, degree)
+		for i := T(0); i < degree; i++ {
+			if err := binary.Read(reader, binary.LittleEndian, &neighbor); err != nil {
+				return nil, err
+			}
+			if err := binary.Read(reader, binary.LittleEndian, &weight); err != nil {
+				return nil, err
+			}
+			neighbors[neighbor] = weight
+		}
+		graph[node] = neighbors
+	}
+
+	return graph, nil
+}
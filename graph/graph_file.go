@@ -0,0 +1,344 @@
+package graph
+
+/*
+Implements a versioned binary graph format, that can be memory mapped for zero copy access.
+
+Unlike WriteNeighborGraphBinary, the file holds a header and an offset index, so a
+node's neighbors can be found without reading the whole file. The layout is the CSR layout:
+
+	header (64 bytes) | nodes | offsets | neighbors | weights
+
+	header: magic "GHGRAPH\0" | version u16 | node width u8 | weight kind u8 | weight width u8 | 3 reserved bytes |
+	        node count u64 | edge count u64 | nodes offset u64 | offsets offset u64 | neighbors offset u64 | weights offset u64
+
+all numbers are little endian, and every section starts at a multiple of 8 bytes
+so that it can be used in place. nodes are sorted, and neighbors sorted per node.
+*/
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/bits"
+	"reflect"
+	"unsafe"
+)
+
+const (
+	graphFileMagic   = "GHGRAPH\x00"
+	graphFileVersion = 1
+	graphHeaderSize  = 64
+)
+
+// The kind of the weights of a graph file.
+const (
+	weightNone uint8 = iota
+	weightUnsigned
+	weightSigned
+	weightFloat
+)
+
+type graphHeader struct {
+	Magic           [8]byte
+	Version         uint16
+	NodeWidth       uint8
+	WeightKind      uint8
+	WeightWidth     uint8
+	_               [3]byte
+	NodeCount       uint64
+	EdgeCount       uint64
+	NodesOffset     uint64
+	OffsetsOffset   uint64
+	NeighborsOffset uint64
+	WeightsOffset   uint64
+}
+
+/*
+The kind and width of W, by its underlying type so that named numbers are stored too.
+only numbers can be stored, any other weight is dropped.
+*/
+func weightKindOf[W any]() (uint8, uint8) {
+	var zero W
+	switch reflect.TypeFor[W]().Kind() {
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		return weightUnsigned, uint8(unsafe.Sizeof(zero))
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		return weightSigned, uint8(unsafe.Sizeof(zero))
+	case reflect.Float32, reflect.Float64:
+		return weightFloat, uint8(unsafe.Sizeof(zero))
+	}
+	return weightNone, 0
+}
+
+func align8(n uint64) uint64 {
+	return (n + 7) &^ 7
+}
+
+func isLittleEndian() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}
+
+// The bytes of a slice of fixed size values, as they are in memory.
+func sliceBytes[E any](s []E) []byte {
+	if len(s) == 0 {
+		return nil
+	}
+	var zero E
+	return unsafe.Slice((*byte)(unsafe.Pointer(&s[0])), len(s)*int(unsafe.Sizeof(zero)))
+}
+
+// A slice of fixed size values over bytes, without copying.
+func bytesSlice[E any](data []byte, count uint64) []E {
+	if count == 0 {
+		return []E{}
+	}
+	return unsafe.Slice((*E)(unsafe.Pointer(&data[0])), count)
+}
+
+/*
+Writes a CSR in the graph file format. weights are written only for weighted CSRs
+with a numeric W. the file goes through a sink like the other outputs, so it is
+compressed by extension, but only uncompressed files can be mapped by MapGraphFile.
+*/
+func WriteGraphFile[T Integer, W any](filename string, csr *CSR[T, W]) error {
+	if !isLittleEndian() {
+		return fmt.Errorf("graph files are little endian, and can't be written on this machine")
+	}
+	return writeFile(filename, func(w io.Writer) error {
+		return writeGraphFileTo(w, csr)
+	})
+}
+
+func writeGraphFileTo[T Integer, W any](w io.Writer, csr *CSR[T, W]) error {
+	var zero T
+	header := graphHeader{
+		Version:   graphFileVersion,
+		NodeWidth: uint8(unsafe.Sizeof(zero)),
+		NodeCount: uint64(len(csr.nodes)),
+		EdgeCount: uint64(len(csr.neighbors)),
+	}
+	copy(header.Magic[:], graphFileMagic)
+	if csr.weights != nil {
+		header.WeightKind, header.WeightWidth = weightKindOf[W]()
+	}
+
+	width := uint64(header.NodeWidth)
+	header.NodesOffset = graphHeaderSize
+	header.OffsetsOffset = align8(header.NodesOffset + header.NodeCount*width)
+	header.NeighborsOffset = align8(header.OffsetsOffset + (header.NodeCount+1)*8)
+	header.WeightsOffset = align8(header.NeighborsOffset + header.EdgeCount*width)
+
+	writer := BufferedWriter(w)
+	if err := binary.Write(writer, binary.LittleEndian, header); err != nil {
+		return err
+	}
+	written := uint64(graphHeaderSize)
+	sections := []struct {
+		offset uint64
+		data   []byte
+	}{
+		{header.NodesOffset, sliceBytes(csr.nodes)},
+		{header.OffsetsOffset, sliceBytes(csr.offsets)},
+		{header.NeighborsOffset, sliceBytes(csr.neighbors)},
+	}
+	if header.WeightKind != weightNone {
+		sections = append(sections, struct {
+			offset uint64
+			data   []byte
+		}{header.WeightsOffset, sliceBytes(csr.weights)})
+	}
+	for _, section := range sections {
+		padding := make([]byte, section.offset-written)
+		if _, err := writer.Write(padding); err != nil {
+			return err
+		}
+		if _, err := writer.Write(section.data); err != nil {
+			return err
+		}
+		written = section.offset + uint64(len(section.data))
+	}
+	return writer.Flush()
+}
+
+/*
+Builds a CSR whose slices point into data, which must hold a whole graph file,
+and must outlive the CSR.
+*/
+func parseGraphFile[T Integer, W any](data []byte) (*CSR[T, W], error) {
+	if !isLittleEndian() {
+		return nil, fmt.Errorf("graph files are little endian, and can't be used in place on this machine")
+	}
+	if len(data) < graphHeaderSize {
+		return nil, fmt.Errorf("file too short for a graph header")
+	}
+	var header graphHeader
+	if _, err := binary.Decode(data[:graphHeaderSize], binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if string(header.Magic[:]) != graphFileMagic {
+		return nil, fmt.Errorf("not a graph file")
+	}
+	if header.Version != graphFileVersion {
+		return nil, fmt.Errorf("unsupported graph file version %d", header.Version)
+	}
+
+	var zeroNode T
+	if header.NodeWidth != uint8(unsafe.Sizeof(zeroNode)) {
+		return nil, fmt.Errorf("graph file has %d byte nodes, expected %d", header.NodeWidth, unsafe.Sizeof(zeroNode))
+	}
+	kind, width := weightKindOf[W]()
+	if header.WeightKind != weightNone && (header.WeightKind != kind || header.WeightWidth != width) {
+		return nil, fmt.Errorf("graph file weights (kind %d, %d bytes) do not match the requested type", header.WeightKind, header.WeightWidth)
+	}
+
+	if header.WeightKind > weightFloat {
+		return nil, fmt.Errorf("unknown graph file weight kind %d", header.WeightKind)
+	}
+	if header.NodeCount == ^uint64(0) {
+		return nil, fmt.Errorf("graph file node count %d is too large", header.NodeCount)
+	}
+
+	nodes, err := graphSection(data, "nodes", header.NodesOffset, header.NodeCount, uint64(header.NodeWidth))
+	if err != nil {
+		return nil, err
+	}
+	offsets, err := graphSection(data, "offsets", header.OffsetsOffset, header.NodeCount+1, 8)
+	if err != nil {
+		return nil, err
+	}
+	neighbors, err := graphSection(data, "neighbors", header.NeighborsOffset, header.EdgeCount, uint64(header.NodeWidth))
+	if err != nil {
+		return nil, err
+	}
+	csr := &CSR[T, W]{
+		nodes:     bytesSlice[T](nodes, header.NodeCount),
+		offsets:   bytesSlice[uint64](offsets, header.NodeCount+1),
+		neighbors: bytesSlice[T](neighbors, header.EdgeCount),
+	}
+	if header.WeightKind != weightNone {
+		weights, err := graphSection(data, "weights", header.WeightsOffset, header.EdgeCount, uint64(header.WeightWidth))
+		if err != nil {
+			return nil, err
+		}
+		csr.weights = bytesSlice[W](weights, header.EdgeCount)
+	}
+
+	// checked once here, so that Neighbors can slice without bounds errors
+	if csr.offsets[0] != 0 || csr.offsets[header.NodeCount] != header.EdgeCount {
+		return nil, fmt.Errorf("graph file offsets do not match its edge count")
+	}
+	for i := uint64(1); i <= header.NodeCount; i++ {
+		if csr.offsets[i] < csr.offsets[i-1] {
+			return nil, fmt.Errorf("graph file offset %d decreases", i)
+		}
+	}
+	return csr, nil
+}
+
+/*
+The bytes of a section of count values of width bytes at offset, checking that it lies
+after the header, is aligned, and fits in data, without overflowing.
+*/
+func graphSection(data []byte, name string, offset, count, width uint64) ([]byte, error) {
+	if offset < graphHeaderSize || offset%8 != 0 {
+		return nil, fmt.Errorf("graph file %v offset %d is invalid", name, offset)
+	}
+	high, size := bits.Mul64(count, width)
+	end, carry := bits.Add64(offset, size, 0)
+	if high != 0 || carry != 0 || end > uint64(len(data)) {
+		return nil, fmt.Errorf("graph file is truncated, %d %v of %d bytes at offset %d", count, name, width, offset)
+	}
+	return data[offset:end], nil
+}
+
+/*
+Reads a whole graph file into memory, decompressing it when compressed. for large
+files prefer MapGraphFile, which only pages in the parts that are used.
+*/
+func ReadGraphFile[T Integer, W any](filename string) (*CSR[T, W], error) {
+	var data []byte
+	err := readFile(filename, func(r io.Reader) (err error) {
+		data, err = io.ReadAll(r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	csr, err := parseGraphFile[T, W](alignedCopy(data))
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", filename, err)
+	}
+	return csr, nil
+}
+
+// Copies data into 8 byte aligned memory, as the sections are used in place.
+func alignedCopy(data []byte) []byte {
+	words := make([]uint64, (len(data)+7)/8)
+	aligned := sliceBytes(words)[:len(data)]
+	copy(aligned, data)
+	return aligned
+}
+
+// A graph file mapped into memory. the CSR must not be used after Close.
+type MappedGraph[T Integer, W any] struct {
+	*CSR[T, W]
+	data []byte
+}
+
+/*
+Memory maps a graph file, giving zero copy access to its neighbors. the file must not
+be compressed, see ReadGraphFile. on systems with no mmap support, the file is read
+into memory instead.
+*/
+func MapGraphFile[T Integer, W any](filename string) (*MappedGraph[T, W], error) {
+	data, err := mapFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	csr, err := parseGraphFile[T, W](data)
+	if err != nil {
+		unmapFile(data)
+		return nil, fmt.Errorf("%v: %w", filename, err)
+	}
+	return &MappedGraph[T, W]{CSR: csr, data: data}, nil
+}
+
+func (m *MappedGraph[T, W]) Close() error {
+	data := m.data
+	m.data, m.CSR = nil, nil
+	return unmapFile(data)
+}
+
+/*
+Converts a file written by WriteNeighborGraphBinary into the graph file format,
+streaming the legacy file into a CSRBuilder. nodes with no neighbors are dropped.
+*/
+func ConvertLegacyBinary[T Integer](legacyFile string, outputFile string) error {
+	reader, err := OpenSource(legacyFile)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	builder := NewCSRBuilder[T, struct{}](false)
+	var node, degree, neighbor T
+	for {
+		if err := binary.Read(reader, binary.LittleEndian, &node); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return fmt.Errorf("%v: %w", legacyFile, err)
+		}
+		if err := binary.Read(reader, binary.LittleEndian, &degree); err != nil {
+			return fmt.Errorf("%v: %w", legacyFile, err)
+		}
+		for i := T(0); i < degree; i++ {
+			if err := binary.Read(reader, binary.LittleEndian, &neighbor); err != nil {
+				return fmt.Errorf("%v: %w", legacyFile, err)
+			}
+			builder.AddEdge(node, neighbor, struct{}{})
+		}
+	}
+	return WriteGraphFile(outputFile, builder.Build())
+}
@@ -0,0 +1,1023 @@
+package graph
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Relations of a test edge, a star and a number of pushes.
+type testRelations struct {
+	Stars, Pushes uint32
+}
+
+func (r testRelations) Relations(yield func(relation string, weight uint32)) {
+	if r.Stars != 0 {
+		yield("star", r.Stars)
+	}
+	if r.Pushes != 0 {
+		yield("push", r.Pushes)
+	}
+}
+
+func TestHeteroGraph(t *testing.T) {
+	collab := Graph[uint32, testRelations]{42: {42: {Stars: 1, Pushes: 3}}}
+	hetero := FromRelationalGraph[uint32, uint32](collab, UserNode, RepoNode)
+	AddGraph(hetero, Graph[uint32, uint32]{7: {42: 1}}, OrgNode, RepoNode, "owns")
+
+	user, repo := Node{UserNode, 42}, Node{RepoNode, 42}
+	if pushes, _ := hetero.Get(user, repo, "push"); pushes != 3 {
+		t.Errorf("expected 3 pushes, got %d", pushes)
+	}
+	if kinds := hetero.Kinds(); kinds[UserNode] != 1 || kinds[RepoNode] != 1 || kinds[OrgNode] != 1 {
+		t.Errorf("unexpected kinds %v", kinds)
+	}
+	if stars := hetero.Project(UserNode, RepoNode, "star"); stars[42][42] != 1 || len(stars) != 1 {
+		t.Errorf("unexpected projection %v", stars)
+	}
+
+	filename := filepath.Join(t.TempDir(), "hetero.txt")
+	HeteroEdgeListOutputGraph(filename, FromGraph(Graph[uint32, uint32]{7: {42: 1}}, OrgNode, RepoNode, "owns"))
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line := strings.TrimSpace(string(data)); line != "org:7 repo:42 owns 1" {
+		t.Errorf("unexpected output %q", line)
+	}
+	if node, err := ParseNode("org:7"); err != nil || node != (Node{OrgNode, 7}) {
+		t.Errorf("unexpected parsed node %v %v", node, err)
+	}
+}
+
+func TestCSR(t *testing.T) {
+	g := Graph[uint32, uint32]{
+		5: {9: 1, 2: 3},
+		1: {5: 7},
+		8: {},
+	}
+	csr := NewCSR(g, true)
+	if csr.NumNodes() != 3 || csr.NumEdges() != 3 {
+		t.Fatalf("unexpected sizes %d %d", csr.NumNodes(), csr.NumEdges())
+	}
+	if neighbors := csr.Neighbors(5); !slices.Equal(neighbors, []uint32{2, 9}) {
+		t.Errorf("expected sorted neighbors, got %v", neighbors)
+	}
+	if weight, ok := csr.Weight(5, 2); !ok || weight != 3 {
+		t.Errorf("unexpected weight %d %v", weight, ok)
+	}
+	if csr.HasEdge(5, 1) || csr.HasEdge(4, 1) || csr.Degree(8) != 0 {
+		t.Errorf("unexpected adjacency")
+	}
+	if !reflect.DeepEqual(csr.ToGraph(), g) {
+		t.Errorf("round trip mismatch %v", csr.ToGraph())
+	}
+
+	builder := NewCSRBuilder[uint32, uint32](true)
+	builder.AddEdge(5, 9, 1)
+	builder.AddEdge(1, 5, 7)
+	builder.AddEdge(5, 2, 100)
+	builder.AddEdge(5, 2, 3) // the later duplicate wins
+	built := builder.Build()
+	delete(g, 8) // streams hold no nodes without edges
+	if !reflect.DeepEqual(built.ToGraph(), g) {
+		t.Errorf("builder mismatch %v", built.ToGraph())
+	}
+}
+
+// A random bipartite graph shaped like the collab graph, with a fixed seed.
+func benchmarkGraph(users, edgesPerUser int) Graph[uint32, uint32] {
+	random := rand.New(rand.NewPCG(1, 2))
+	g := make(Graph[uint32, uint32], users)
+	for user := 0; user < users; user++ {
+		neighbors := make(map[uint32]uint32, edgesPerUser)
+		for len(neighbors) < edgesPerUser {
+			neighbors[random.Uint32()] = random.Uint32N(100)
+		}
+		g[random.Uint32()] = neighbors
+	}
+	return g
+}
+
+func heapAlloc() uint64 {
+	var m runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// Measures the heap held per edge by building fn, reported as B/edge.
+func benchmarkMemory[G any](b *testing.B, edges int, fn func() G) {
+	var kept G
+	perEdge := 0.0
+	for i := 0; i < b.N; i++ {
+		before := heapAlloc()
+		kept = fn()
+		perEdge = float64(heapAlloc()-before) / float64(edges)
+	}
+	runtime.KeepAlive(kept)
+	b.ReportMetric(perEdge, "B/edge")
+}
+
+func BenchmarkMemoryGraph(b *testing.B) {
+	source := benchmarkGraph(20000, 10)
+	b.ResetTimer()
+	benchmarkMemory(b, 200000, func() Graph[uint32, uint32] {
+		g := make(Graph[uint32, uint32], len(source))
+		for node, neighbors := range source {
+			g[node] = make(map[uint32]uint32)
+			for neighbor, weight := range neighbors {
+				g[node][neighbor] = weight
+			}
+		}
+		return g
+	})
+}
+
+func BenchmarkMemoryCSR(b *testing.B) {
+	source := benchmarkGraph(20000, 10)
+	b.ResetTimer()
+	benchmarkMemory(b, 200000, func() *CSR[uint32, uint32] {
+		return NewCSR(source, true)
+	})
+}
+
+func BenchmarkCSRHasEdge(b *testing.B) {
+	source := benchmarkGraph(20000, 10)
+	csr := NewCSR(source, false)
+	nodes := csr.Nodes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		node := nodes[i%len(nodes)]
+		csr.HasEdge(node, csr.Neighbors(node)[i%10])
+	}
+}
+
+func TestRemapBinaryRoundTrip(t *testing.T) {
+	g := map[uint64]map[uint64]struct{}{
+		1 << 40: {7: {}, 1 << 40: {}}, // a user and a repo sharing an id
+		3:       {7: {}},
+	}
+	filename := filepath.Join(t.TempDir(), "collab.bin")
+	if err := WriteNeighborGraphBinaryRemapped(filename, g, UserNode, RepoNode); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadNeighborGraphBinaryRemapped[uint64](filename, UserNode, RepoNode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, g) {
+		t.Errorf("round trip mismatch %v", read)
+	}
+
+	r, err := ReadRemapper[uint64](RemapFile(filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Len(UserNode) != 2 || r.Len(RepoNode) != 2 {
+		t.Errorf("expected 2 users and 2 repos, got %d %d", r.Len(UserNode), r.Len(RepoNode))
+	}
+	var buf bytes.Buffer
+	if err := WriteRemapperTo(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	if back, err := ReadRemapperFrom[uint64](&buf); err != nil || !reflect.DeepEqual(back, r) {
+		t.Errorf("remapper reader round trip %+v %v", back, err)
+	}
+
+	builder := NewRemappedCSRBuilder[uint64, struct{}](r, UserNode, RepoNode, false)
+	builder.AddEdge(3, 7, struct{}{})
+	csr := builder.Build()
+	user, _ := r.Lookup(UserNode, 3)
+	repo, _ := r.Lookup(RepoNode, 7)
+	if !csr.HasEdge(user, repo) || csr.NumEdges() != 1 {
+		t.Errorf("unexpected remapped csr")
+	}
+}
+
+func TestRemapTransparentRead(t *testing.T) {
+	g := Graph[uint64, uint32]{1 << 40: {7: 2}, 3: {7: 1, 9: 4}}
+	dir := t.TempDir()
+
+	edgeList := filepath.Join(dir, "collab.txt.gz")
+	if err := EdgeListOutputGraphRemapped(edgeList, g, UserNode, RepoNode); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := ReadEdgeList[uint64, uint32](edgeList, DefaultEdgeListOptions()); err != nil || !reflect.DeepEqual(read, g) {
+		t.Errorf("expected the original ids, got %v %v", read, err)
+	}
+
+	unweighted := Graph[uint64, struct{}]{1 << 40: {7: {}}, 3: {9: {}}}
+	neighbors := filepath.Join(dir, "collab.neighbors")
+	if err := NeighborOutputGraphRemapped(neighbors, unweighted, UserNode, RepoNode); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := ReadNeighborGraph[uint64](neighbors); err != nil || !reflect.DeepEqual(read, unweighted) {
+		t.Errorf("expected the original ids, got %v %v", read, err)
+	}
+
+	// writing the file again without remapping removes the stale mapping
+	if err := EdgeListOutputGraph(edgeList, g); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(RemapFile(edgeList)); !os.IsNotExist(err) {
+		t.Errorf("expected the stale mapping to be removed, got %v", err)
+	}
+	if read, err := ReadEdgeList[uint64, uint32](edgeList, DefaultEdgeListOptions()); err != nil || !reflect.DeepEqual(read, g) {
+		t.Errorf("expected the ids as written, got %v %v", read, err)
+	}
+
+	// mappings of other layouts, as the interchange formats write, are left alone
+	mtx := filepath.Join(dir, "g.txt")
+	if err := WriteSNAP(mtx, Graph[uint32, struct{}]{10: {20: {}}}, UserNode, UserNode, ""); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := ReadEdgeList[uint32, struct{}](mtx, DefaultEdgeListOptions()); err != nil || !reflect.DeepEqual(read, Graph[uint32, struct{}]{0: {1: {}}}) {
+		t.Errorf("expected the dense indices, got %v %v", read, err)
+	}
+}
+
+func TestReadCorruptRemapper(t *testing.T) {
+	header := remapMagic + string([]byte{remapVersion, byte(NumNodeKinds), byte(NumNodeKinds)})
+	uvarint := func(v uint64) string {
+		return string(binary.AppendUvarint(nil, v))
+	}
+	cases := map[string]string{
+		"huge count":     header + uvarint(1) + string([]byte{byte(UserNode)}) + uvarint(math.MaxUint64),
+		"truncated ids":  header + uvarint(1) + string([]byte{byte(UserNode)}) + uvarint(1<<30) + uvarint(1),
+		"duplicate id":   header + uvarint(1) + string([]byte{byte(UserNode)}) + uvarint(2) + uvarint(5) + uvarint(5),
+		"overflowing id": header + uvarint(1) + string([]byte{byte(UserNode)}) + uvarint(1) + uvarint(1<<40),
+		"kind twice":     header + uvarint(2) + string([]byte{byte(UserNode)}) + uvarint(0) + string([]byte{byte(UserNode)}) + uvarint(0),
+		"unknown kind":   header + uvarint(1) + string([]byte{byte(NumNodeKinds)}) + uvarint(0),
+		"too many kinds": header + uvarint(1<<20),
+		"one role":       remapMagic + string([]byte{remapVersion, byte(UserNode), byte(NumNodeKinds)}) + uvarint(0),
+		"old version":    remapMagic + string([]byte{1}) + uvarint(0),
+	}
+	dir := t.TempDir()
+	for name, content := range cases {
+		filename := filepath.Join(dir, "ids")
+		if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadRemapper[uint32](filename); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
+
+func TestGraphFile(t *testing.T) {
+	dir := t.TempDir()
+	weighted := NewCSR(Graph[uint32, float32]{1: {2: 0.5, 3: 1.5}, 5: {1: 2}}, true)
+	filename := filepath.Join(dir, "weighted.ghg")
+	if err := WriteGraphFile(filename, weighted); err != nil {
+		t.Fatal(err)
+	}
+	mapped, err := MapGraphFile[uint32, float32](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(mapped.ToGraph(), weighted.ToGraph()) {
+		t.Errorf("mapped graph mismatch %v", mapped.ToGraph())
+	}
+	if w, ok := mapped.Weight(1, 3); !ok || w != 1.5 {
+		t.Errorf("unexpected weight %v", w)
+	}
+	if err := mapped.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadGraphFile[uint64, float32](filename); err == nil {
+		t.Errorf("expected an error reading 4 byte nodes as uint64")
+	}
+	if _, err := ReadGraphFile[uint32, uint32](filename); err == nil {
+		t.Errorf("expected an error reading float weights as uint32")
+	}
+
+	// graph files go through a sink, compressed ones are read into memory but can't be mapped
+	compressed := filepath.Join(dir, "weighted.ghg.gz")
+	if err := WriteGraphFile(compressed, weighted); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := ReadGraphFile[uint32, float32](compressed); err != nil || !reflect.DeepEqual(read.ToGraph(), weighted.ToGraph()) {
+		t.Errorf("compressed graph file mismatch %v", err)
+	}
+	if mapped, err := MapGraphFile[uint32, float32](compressed); err == nil {
+		mapped.Close()
+		t.Errorf("expected an error mapping a compressed graph file")
+	}
+
+	legacy := filepath.Join(dir, "legacy.bin")
+	g := map[uint32]map[uint32]struct{}{1: {4: {}, 2: {}}, 3: {}, 9: {1: {}}}
+	if err := WriteNeighborGraphBinary(legacy, g); err != nil {
+		t.Fatal(err)
+	}
+	converted := filepath.Join(dir, "legacy.ghg")
+	if err := ConvertLegacyBinary[uint32](legacy, converted); err != nil {
+		t.Fatal(err)
+	}
+	csr, err := ReadGraphFile[uint32, struct{}](converted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if csr.NumEdges() != 3 || !slices.Equal(csr.Neighbors(1), []uint32{2, 4}) || csr.Weighted() {
+		t.Errorf("unexpected converted graph %v", csr.ToGraph())
+	}
+}
+
+// A named weight type, stored by its underlying type.
+type testWeight uint16
+
+func TestCorruptGraphFile(t *testing.T) {
+	csr := NewCSR(Graph[uint32, testWeight]{1: {2: 5, 3: 6}, 5: {1: 7}}, true)
+	filename := filepath.Join(t.TempDir(), "g.ghg")
+	if err := WriteGraphFile(filename, csr); err != nil {
+		t.Fatal(err)
+	}
+	valid, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read, err := parseGraphFile[uint32, testWeight](alignedCopy(valid)); err != nil || !read.Weighted() {
+		t.Fatalf("expected named weights to be stored, got %v", err)
+	}
+
+	// header fields by byte position, see graphHeader
+	const nodeCount, edgeCount, nodesOffset, offsetsOffset, neighborsOffset, weightsOffset = 16, 24, 32, 40, 48, 56
+	offsetsAt := binary.LittleEndian.Uint64(valid[offsetsOffset:])
+	cases := map[string]func(data []byte){
+		"huge node count":       func(data []byte) { binary.LittleEndian.PutUint64(data[nodeCount:], math.MaxUint64) },
+		"overflowing nodes":     func(data []byte) { binary.LittleEndian.PutUint64(data[nodeCount:], math.MaxUint64/2) },
+		"huge edge count":       func(data []byte) { binary.LittleEndian.PutUint64(data[edgeCount:], 1<<62) },
+		"nodes in the header":   func(data []byte) { binary.LittleEndian.PutUint64(data[nodesOffset:], 8) },
+		"unaligned offsets":     func(data []byte) { binary.LittleEndian.PutUint64(data[offsetsOffset:], offsetsAt+1) },
+		"overflowing neighbors": func(data []byte) { binary.LittleEndian.PutUint64(data[neighborsOffset:], math.MaxUint64-7) },
+		"weights past the end":  func(data []byte) { binary.LittleEndian.PutUint64(data[weightsOffset:], uint64(len(data))) },
+		"unknown weight kind":   func(data []byte) { data[11] = 9 },
+		"decreasing offsets":    func(data []byte) { binary.LittleEndian.PutUint64(data[offsetsAt+8:], 4) },
+		"nonzero first offset":  func(data []byte) { binary.LittleEndian.PutUint64(data[offsetsAt:], 1) },
+	}
+	for name, corrupt := range cases {
+		data := alignedCopy(valid)
+		corrupt(data)
+		if _, err := parseGraphFile[uint32, testWeight](data); err == nil {
+			t.Errorf("%v: expected an error", name)
+		}
+	}
+}
+
+func TestWeightedBinaryRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	g := Graph[uint32, float64]{1: {2: 0.25, 3: 7}, 4: {1: 1e9}, 5: {}}
+	text := filepath.Join(dir, "weighted.txt")
+	EdgeListOutputGraph(text, g)
+	binaryFile := filepath.Join(dir, "weighted.bin")
+	if err := WriteWeightedNeighborGraphBinary(binaryFile, g); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadWeightedNeighborGraphBinary[uint32, float64](binaryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edges := 0
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var src, target uint32
+		var weight float64
+		if _, err := fmt.Sscan(line, &src, &target, &weight); err != nil {
+			t.Fatal(err)
+		}
+		if w, ok := read[src][target]; !ok || w != weight {
+			t.Errorf("edge %d %d: text has %v, binary has %v", src, target, weight, w)
+		}
+		edges++
+	}
+	if edges != 3 || len(read) != 3 || len(read[5]) != 0 {
+		t.Errorf("unexpected round trip %v", read)
+	}
+
+	integer := Graph[uint64, int16]{1 << 40: {2: -3}}
+	if err := WriteWeightedNeighborGraphBinary(binaryFile, integer); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := ReadWeightedNeighborGraphBinary[uint64, int16](binaryFile); err != nil || !reflect.DeepEqual(read, integer) {
+		t.Errorf("integer weights round trip %v %v", read, err)
+	}
+}
+
+func TestReadEdgeList(t *testing.T) {
+	dir := t.TempDir()
+	weighted := Graph[uint32, uint32]{1: {2: 3, 4: 1}, 2: {1: 5}}
+	filename := filepath.Join(dir, "weighted.txt")
+	EdgeListOutputGraph(filename, weighted)
+	read, err := ReadEdgeList[uint32, uint32](filename, DefaultEdgeListOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, weighted) {
+		t.Errorf("weighted round trip %v", read)
+	}
+
+	unweighted := Graph[uint32, struct{}]{1: {2: {}}, 3: {2: {}}}
+	EdgeListOutputGraph(filename, unweighted)
+	if read, err := ReadEdgeList[uint32, struct{}](filename, DefaultEdgeListOptions()); err != nil || !reflect.DeepEqual(read, unweighted) {
+		t.Errorf("unweighted round trip %v %v", read, err)
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("% a comment\nsrc,target,weight\n1, 2, 0.5\n\n3,1,2\n"))
+	gz.Close()
+	options := EdgeListOptions{Delimiter: ",", Comment: "%", HeaderRows: 1}
+	var edges []string
+	err = ScanEdgeList(&buf, options, func(src, target uint64, weight float64) error {
+		edges = append(edges, fmt.Sprint(src, target, weight))
+		return nil
+	})
+	if err != nil || !slices.Equal(edges, []string{"1 2 0.5", "3 1 2"}) {
+		t.Errorf("unexpected edges %v %v", edges, err)
+	}
+
+	compressed := filepath.Join(filepath.Dir(filename), "streamed.txt.zst")
+	EdgeListOutputGraph(compressed, weighted)
+	streamed := 0
+	err = ReadEdgeListFunc(compressed, DefaultEdgeListOptions(), func(src, target uint32, weight uint32) error {
+		if weighted[src][target] != weight {
+			t.Errorf("unexpected edge %d %d %v", src, target, weight)
+		}
+		streamed++
+		return nil
+	})
+	if err != nil || streamed != 3 {
+		t.Errorf("expected 3 edges streamed from the zstd file, got %d %v", streamed, err)
+	}
+
+	err = ScanEdgeList(strings.NewReader("1 2\n"), DefaultEdgeListOptions(), func(src, target uint32, weight uint32) error {
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected an error for a missing weight, got %v", err)
+	}
+}
+
+func TestGraphMLAndGEXF(t *testing.T) {
+	dir := t.TempDir()
+	g := Graph[uint32, uint32]{1: {2: 3, 7: 1}, 5: {2: 1}}
+	label := func(id uint32) string { return fmt.Sprintf("<user %d>", id) }
+
+	graphml := filepath.Join(dir, "g.graphml")
+	if err := WriteGraphML(graphml, g, UserNode, RepoNode, label, nil); err != nil {
+		t.Fatal(err)
+	}
+	var ml struct {
+		Nodes []struct {
+			ID   string `xml:"id,attr"`
+			Data []struct {
+				Key   string `xml:"key,attr"`
+				Value string `xml:",chardata"`
+			} `xml:"data"`
+		} `xml:"graph>node"`
+		Edges []struct {
+			Source string `xml:"source,attr"`
+			Weight string `xml:"data"`
+		} `xml:"graph>edge"`
+	}
+	data, _ := os.ReadFile(graphml)
+	if err := xml.Unmarshal(data, &ml); err != nil {
+		t.Fatal(err)
+	}
+	if len(ml.Nodes) != 4 || len(ml.Edges) != 3 {
+		t.Fatalf("expected 4 nodes and 3 edges, got %+v", ml)
+	}
+	if first := ml.Nodes[0]; first.ID != "user:1" || first.Data[1].Value != "<user 1>" || first.Data[2].Value != "2" {
+		t.Errorf("unexpected first node %+v", first)
+	}
+	if repo := ml.Nodes[2]; repo.ID != "repo:2" || repo.Data[2].Value != "2" {
+		t.Errorf("unexpected repo node %+v", repo)
+	}
+
+	// labeled weights get an edge key of their own, as key ids are unique in the document
+	var labeled bytes.Buffer
+	if err := WriteGraphMLTo(&labeled, Graph[uint32, string]{1: {2: "fork"}}, UserNode, RepoNode, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	var keys struct {
+		Keys []struct {
+			ID  string `xml:"id,attr"`
+			For string `xml:"for,attr"`
+		} `xml:"key"`
+		Edges []struct {
+			Data struct {
+				Key string `xml:"key,attr"`
+			} `xml:"data"`
+		} `xml:"graph>edge"`
+	}
+	if err := xml.Unmarshal(labeled.Bytes(), &keys); err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]bool)
+	for _, key := range keys.Keys {
+		if ids[key.ID] {
+			t.Errorf("key id %q declared twice", key.ID)
+		}
+		ids[key.ID] = true
+	}
+	if len(keys.Edges) != 1 || keys.Edges[0].Data.Key != "edgelabel" || !ids["edgelabel"] {
+		t.Errorf("expected edges labeled by the edgelabel key, got %+v", keys)
+	}
+
+	gexf := filepath.Join(dir, "g.gexf")
+	if err := WriteGEXF(gexf, Graph[uint32, struct{}]{1: {1: {}}}, UserNode, UserNode, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	var gx struct {
+		Nodes []struct {
+			ID    string `xml:"id,attr"`
+			Label string `xml:"label,attr"`
+		} `xml:"graph>nodes>node"`
+		Edges []struct {
+			Weight *string `xml:"weight,attr"`
+		} `xml:"graph>edges>edge"`
+	}
+	data, _ = os.ReadFile(gexf)
+	if err := xml.Unmarshal(data, &gx); err != nil {
+		t.Fatal(err)
+	}
+	if len(gx.Nodes) != 1 || gx.Nodes[0].Label != "1" || len(gx.Edges) != 1 || gx.Edges[0].Weight != nil {
+		t.Errorf("unexpected gexf %+v", gx)
+	}
+}
+
+func TestInterchangeFormats(t *testing.T) {
+	dir := t.TempDir()
+	read := func(filename string) string {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	bipartite := Graph[uint32, uint32]{10: {7: 2, 3: 1}, 5: {7: 4}}
+
+	mtx := filepath.Join(dir, "g.mtx")
+	if err := WriteMatrixMarket(mtx, bipartite, UserNode, RepoNode); err != nil {
+		t.Fatal(err)
+	}
+	expected := "%%MatrixMarket matrix coordinate integer general\n" +
+		"% rows are user nodes, columns are repo nodes, ids in g.mtx.ids\n" +
+		"2 2 3\n1 2 4\n2 1 1\n2 2 2\n"
+	if got := read(mtx); got != expected {
+		t.Errorf("unexpected matrix market:\n%s", got)
+	}
+	r, err := ReadRemapper[uint32](RemapFile(mtx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := r.Sparse(UserNode, 1); user != 10 {
+		t.Errorf("expected row 2 to be user 10, got %d", user)
+	}
+
+	snap := filepath.Join(dir, "g.txt")
+	if err := WriteSNAP(snap, Graph[uint32, struct{}]{1: {2: {}}}, UserNode, UserNode, "test graph"); err != nil {
+		t.Fatal(err)
+	}
+	expected = "# Directed graph (each unordered pair of nodes is saved once): g.txt\n# test graph\n" +
+		"# Nodes: 2 Edges: 1\n# FromNodeId\tToNodeId\n0\t1\n"
+	if got := read(snap); got != expected {
+		t.Errorf("unexpected snap:\n%s", got)
+	}
+
+	pajek := filepath.Join(dir, "g.net")
+	label := func(id uint32) string { return fmt.Sprintf("\"r%d\"", id) }
+	if err := WritePajek(pajek, bipartite, UserNode, RepoNode, nil, label); err != nil {
+		t.Fatal(err)
+	}
+	expected = "*Vertices 4 2\n1 \"5\"\n2 \"10\"\n3 \"'r3'\"\n4 \"'r7'\"\n*Arcs\n1 4 4\n2 3 1\n2 4 2\n"
+	if got := read(pajek); got != expected {
+		t.Errorf("unexpected pajek:\n%s", got)
+	}
+
+	metis := filepath.Join(dir, "g.graph")
+	users := Graph[uint32, uint32]{1: {2: 3, 1: 9}, 2: {1: 1, 3: 1}}
+	if err := WriteWeightedMETIS(metis, users, UserNode, UserNode); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(metis); got != "3 2 001\n2 4\n1 4 3 1\n2 1\n" {
+		t.Errorf("unexpected metis:\n%s", got)
+	}
+	if err := WriteMETIS(metis, users, UserNode, UserNode); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(metis); got != "3 2\n2\n1 3\n2\n" {
+		t.Errorf("unexpected unweighted metis:\n%s", got)
+	}
+	for _, invalid := range []Graph[int32, int32]{{1: {2: 0}}, {1: {2: -3}, 2: {1: 5}}} {
+		if _, err := WriteWeightedMETISTo(io.Discard, invalid, UserNode, UserNode); err == nil {
+			t.Errorf("expected an error for non positive metis weights %v", invalid)
+		}
+	}
+	if _, err := WriteWeightedMETISTo(io.Discard, Graph[int32, int32]{1: {1: 0, 2: 1}}, UserNode, UserNode); err != nil {
+		t.Errorf("self loops are dropped whatever their weight, got %v", err)
+	}
+}
+
+func TestParquetEdges(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "edges.parquet")
+	if err := WriteParquetEdges(filename, Graph[uint32, uint32]{1: {2: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadParquetEdges(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Src != 1 || rows[0].Dst != 2 || rows[0].Weight != 3 || rows[0].Type != nil {
+		t.Errorf("unexpected rows %+v", rows)
+	}
+
+	relational := Graph[uint32, testRelations]{1: {2: {Stars: 1, Pushes: 4}}}
+	if err := WriteRelationParquetEdges[uint32, uint32](filename, relational); err != nil {
+		t.Fatal(err)
+	}
+	if rows, err = ReadParquetEdges(filename); err != nil {
+		t.Fatal(err)
+	}
+	weights := make(map[string]float64)
+	for _, row := range rows {
+		weights[*row.Type] = row.Weight
+	}
+	if !reflect.DeepEqual(weights, map[string]float64{"star": 1, "push": 4}) {
+		t.Errorf("unexpected relation rows %v", weights)
+	}
+
+	temporal := Graph[uint32, TemporalEdge]{1: {2: {First: 10, Last: 20, Count: 2}}}
+	if err := WriteTemporalParquetEdges(filename, temporal); err != nil {
+		t.Fatal(err)
+	}
+	if rows, err = ReadParquetEdges(filename); err != nil || *rows[0].Timestamp != 20000 || rows[0].Weight != 2 {
+		t.Errorf("unexpected temporal rows %+v %v", rows, err)
+	}
+
+	if err := WriteParquetEdges(filename, Graph[uint32, testWeight]{1: {2: 5}}); err != nil {
+		t.Fatal(err)
+	}
+	if rows, err = ReadParquetEdges(filename); err != nil || rows[0].Weight != 5 {
+		t.Errorf("named weights should be written by value, got %+v %v", rows, err)
+	}
+}
+
+func TestArrowEdges(t *testing.T) {
+	var buffer bytes.Buffer
+	writer, err := NewArrowWriter[ParquetEdge](&buffer, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeEdgeRows(writer, Graph[uint32, testWeight]{7: {9: 5}}); err != nil {
+		t.Fatal(err)
+	}
+	data := buffer.Bytes()
+	if !bytes.HasPrefix(data, []byte("ARROW1\x00\x00\xff\xff\xff\xff")) || !bytes.HasSuffix(data, []byte("ARROW1")) {
+		t.Fatalf("missing arrow file magic or first message")
+	}
+	footer := int(binary.LittleEndian.Uint32(data[len(data)-10:]))
+	eos := len(data) - 10 - footer - 8
+	if eos < 8 || binary.LittleEndian.Uint64(data[eos:]) != 0x00000000FFFFFFFF {
+		t.Fatalf("footer of %d bytes should follow the end of stream marker", footer)
+	}
+	for _, name := range []string{"src", "dst", "weight", "type", "timestamp"} {
+		if !bytes.Contains(data[eos:], []byte(name)) {
+			t.Errorf("footer schema misses column %s", name)
+		}
+	}
+	// the src, dst and weight buffers of the batch, each padded to 8 bytes
+	values := binary.LittleEndian.AppendUint64(nil, 7)
+	values = binary.LittleEndian.AppendUint64(values, 9)
+	values = binary.LittleEndian.AppendUint64(values, math.Float64bits(5))
+	if !bytes.Contains(data, values) {
+		t.Errorf("record batch misses the edge values")
+	}
+
+	filename := filepath.Join(t.TempDir(), "edges.arrows")
+	if err := WriteArrowEdges(filename, Graph[uint32, uint32]{1: {2: 3}}); err != nil {
+		t.Fatal(err)
+	}
+	if data, err = os.ReadFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.HasPrefix(data, []byte("ARROW1")) || !bytes.HasSuffix(data, binary.LittleEndian.AppendUint64(nil, 0x00000000FFFFFFFF)) {
+		t.Errorf("stream files should have no magic and end with the end of stream marker")
+	}
+}
+
+func TestSortedOutputs(t *testing.T) {
+	dir := t.TempDir()
+	g := benchmarkGraph(50, 20)
+	read := func(filename string) string {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	inMemory := filepath.Join(dir, "memory.txt")
+	if err := SortedEdgeListOutputGraph(inMemory, g); err != nil {
+		t.Fatal(err)
+	}
+	sorter, err := NewEdgeSorter[uint32, uint32](64, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sorter.Close()
+	if err := sorter.AddGraph(g); err != nil {
+		t.Fatal(err)
+	}
+	external := filepath.Join(dir, "external.txt")
+	if err := sorter.EdgeListOutput(external); err != nil {
+		t.Fatal(err)
+	}
+	if sorter.Runs() < 2 || read(inMemory) != read(external) {
+		t.Errorf("external sort (%d runs) differs from the in memory sort", sorter.Runs())
+	}
+	lines := strings.Split(strings.TrimSpace(read(inMemory)), "\n")
+	if !slices.IsSortedFunc(lines, func(a, b string) int {
+		var x, y [2]uint32
+		fmt.Sscan(a, &x[0], &x[1])
+		fmt.Sscan(b, &y[0], &y[1])
+		return slices.Compare(x[:], y[:])
+	}) {
+		t.Errorf("edge list is not sorted")
+	}
+
+	neighbors := filepath.Join(dir, "neighbors.txt")
+	if err := SortedNeighborOutputGraph(neighbors, Graph[uint32, struct{}]{3: {9: {}, 1: {}}, 2: {}}); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(neighbors); got != "2\n3 1 9\n" {
+		t.Errorf("unexpected neighbors %q", got)
+	}
+
+	unweighted := map[uint32]map[uint32]struct{}{3: {9: {}, 1: {}}, 2: {}, 7: {3: {}}}
+	first, second := filepath.Join(dir, "first.bin"), filepath.Join(dir, "second.bin")
+	WriteNeighborGraphBinarySorted(first, unweighted)
+	WriteNeighborGraphBinarySorted(second, unweighted)
+	if read(first) != read(second) {
+		t.Errorf("sorted binary outputs differ")
+	}
+	if back, err := ReadNeighborGraphBinary[uint32](first); err != nil || !reflect.DeepEqual(back, unweighted) {
+		t.Errorf("sorted binary round trip %v %v", back, err)
+	}
+
+	if _, err := NewEdgeSorter[uint32, string](10, dir); err == nil {
+		t.Errorf("expected an error for variable size weights")
+	}
+
+	// more runs than are merged at once are merged in several passes
+	passes, err := NewEdgeSorter[uint32, uint32](16, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer passes.Close()
+	passes.fanIn = 3
+	passes.AddGraph(g)
+	spilled := passes.Runs()
+	multiPass := filepath.Join(dir, "passes.txt")
+	if err := passes.EdgeListOutput(multiPass); err != nil {
+		t.Fatal(err)
+	}
+	if spilled <= passes.fanIn || passes.Runs() > passes.fanIn || read(multiPass) != read(inMemory) {
+		t.Errorf("multi pass merge of %d runs (%d left) differs from the in memory sort", spilled, passes.Runs())
+	}
+	if runs, _ := filepath.Glob(filepath.Join(dir, "edges-*.run")); len(runs) != passes.Runs()+sorter.Runs() {
+		t.Errorf("expected merged runs to be removed, found %d", len(runs))
+	}
+
+	// duplicate edges, within and across runs, collapse into the last added as in a Graph
+	for _, fanIn := range []int{maxMergeRuns, 2} {
+		duplicates, err := NewEdgeSorter[uint32, uint32](4, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		duplicates.fanIn = fanIn
+		for i := uint32(0); i < 20; i++ {
+			duplicates.Add(1, 2, i)
+			duplicates.Add(1, i%3+3, i)
+			duplicates.Add(i%2, 9, i)
+		}
+		var edges, nodes, binaryNodes bytes.Buffer
+		if err := duplicates.WriteEdgeList(&edges); err != nil {
+			t.Fatal(err)
+		}
+		duplicates.WriteNeighbors(&nodes)
+		duplicates.WriteNeighborsBinary(&binaryNodes)
+		runs := duplicates.Runs()
+		duplicates.Close()
+
+		expected := Graph[uint32, uint32]{0: {9: 18}, 1: {2: 19, 3: 18, 4: 19, 5: 17, 9: 19}}
+		var wantEdges, wantNodes, wantBinary bytes.Buffer
+		WriteSortedEdgeList(&wantEdges, expected)
+		WriteSortedNeighbors(&wantNodes, expected)
+		WriteNeighborsBinarySorted(&wantBinary, map[uint32]map[uint32]struct{}{0: {9: {}}, 1: {2: {}, 3: {}, 4: {}, 5: {}, 9: {}}})
+		if edges.String() != wantEdges.String() || nodes.String() != wantNodes.String() || !bytes.Equal(binaryNodes.Bytes(), wantBinary.Bytes()) {
+			t.Errorf("duplicates merged from %d runs (fan in %d) differ from a graph:\n%s\n%s", runs, fanIn, edges.String(), nodes.String())
+		}
+	}
+
+	label := func(id uint32) string { return fmt.Sprintf("n%d", id) }
+	var labeled bytes.Buffer
+	if err := WriteSortedLabeledNeighbors(&labeled, Graph[uint32, struct{}]{3: {9: {}, 1: {}}, 2: {}}, label, label); err != nil || labeled.String() != "n2\nn3 n1 n9\n" {
+		t.Errorf("unexpected labeled neighbors %q %v", labeled.String(), err)
+	}
+	labeled.Reset()
+	if err := WriteSortedLabeledEdgeList(&labeled, Graph[uint32, uint32]{3: {9: 1, 1: 2}}, label, label); err != nil || labeled.String() != "n3 n1 2\nn3 n9 1\n" {
+		t.Errorf("unexpected labeled edge list %q %v", labeled.String(), err)
+	}
+
+	// remapped outputs are deterministic
+	dense, again := filepath.Join(dir, "dense.bin"), filepath.Join(dir, "again.bin")
+	WriteNeighborGraphBinaryRemapped(dense, unweighted, UserNode, RepoNode)
+	WriteNeighborGraphBinaryRemapped(again, unweighted, UserNode, RepoNode)
+	if read(dense) != read(again) || read(RemapFile(dense)) != read(RemapFile(again)) {
+		t.Errorf("remapped binary outputs differ")
+	}
+}
+
+// Records the largest write it gets.
+type largestWriter struct{ largest int }
+
+func (w *largestWriter) Write(p []byte) (int, error) {
+	w.largest = max(w.largest, len(p))
+	return len(p), nil
+}
+
+// Writers given a buffered writer, as writeFile gives them the sink's, write through it.
+func TestBufferedOnce(t *testing.T) {
+	var target largestWriter
+	small := bufio.NewWriterSize(&target, 64)
+	if err := WriteEdgeList(small, benchmarkGraph(20, 20)); err != nil {
+		t.Fatal(err)
+	}
+	if target.largest > 64 {
+		t.Errorf("expected writes of at most the 64 byte buffer, got %d bytes", target.largest)
+	}
+}
+
+func TestCompressedSinks(t *testing.T) {
+	dir := t.TempDir()
+	g := Graph[uint32, uint32]{1: {2: 3, 4: 5}, 6: {1: 1}}
+	for _, ext := range []string{"", ".gz", ".zst"} {
+		filename := filepath.Join(dir, "edges.txt"+ext)
+		EdgeListOutputGraph(filename, g)
+		data, _ := os.ReadFile(filename)
+		if compressed := bytes.HasPrefix(data, gzipMagic) || bytes.HasPrefix(data, zstdMagic); compressed != (ext != "") {
+			t.Errorf("%v output is not compressed", ext)
+		}
+		read, err := ReadEdgeList[uint32, uint32](filename, DefaultEdgeListOptions())
+		if err != nil || !reflect.DeepEqual(read, g) {
+			t.Errorf("%v round trip %v %v", ext, read, err)
+		}
+
+		binaryFile := filepath.Join(dir, "graph.bin"+ext)
+		unweighted := map[uint32]map[uint32]struct{}{1: {2: {}}}
+		if err := WriteNeighborGraphBinary(binaryFile, unweighted); err != nil {
+			t.Fatal(err)
+		}
+		if read, err := ReadNeighborGraphBinary[uint32](binaryFile); err != nil || !reflect.DeepEqual(read, unweighted) {
+			t.Errorf("%v binary round trip %v %v", ext, read, err)
+		}
+	}
+
+	// compression is detected from the content, not the name
+	renamed := filepath.Join(dir, "renamed.txt")
+	if err := os.Rename(filepath.Join(dir, "edges.txt.zst"), renamed); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := ReadNeighborGraph[uint32](renamed); err != nil || len(read) != 2 {
+		t.Errorf("expected the renamed zstd file to be read, got %v %v", read, err)
+	}
+}
+
+func TestReaderWriterAPI(t *testing.T) {
+	g := Graph[uint32, uint32]{1: {2: 3, 4: 5}, 6: {1: 1}}
+
+	var buf bytes.Buffer
+	if err := WriteEdgeList(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := ReadEdges[uint32, uint32](&buf, DefaultEdgeListOptions()); err != nil || !reflect.DeepEqual(read, g) {
+		t.Errorf("edge list round trip %v %v", read, err)
+	}
+
+	unweighted := map[uint32]map[uint32]struct{}{1: {2: {}, 3: {}}, 4: {1: {}}}
+	buf.Reset()
+	if err := WriteNeighbors(&buf, Graph[uint32, struct{}](unweighted)); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := ReadNeighbors[uint32](&buf); err != nil || !reflect.DeepEqual(map[uint32]map[uint32]struct{}(read), unweighted) {
+		t.Errorf("neighbors round trip %v %v", read, err)
+	}
+	buf.Reset()
+	if err := WriteNeighborsBinary(&buf, unweighted); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := ReadNeighborsBinary[uint32](&buf); err != nil || !reflect.DeepEqual(read, unweighted) {
+		t.Errorf("binary round trip %v %v", read, err)
+	}
+
+	// with no file name, the interchange formats do not mention the mapping file
+	buf.Reset()
+	r, err := WriteMatrixMarketTo(&buf, g, UserNode, RepoNode)
+	if err != nil || r.Len(UserNode) != 2 || r.Len(RepoNode) != 3 {
+		t.Fatalf("matrix market %v %v", r, err)
+	}
+	if !strings.Contains(buf.String(), "% rows are user nodes, columns are repo nodes\n") {
+		t.Errorf("unexpected matrix market header %q", buf.String())
+	}
+
+	// partitioned relation rows are the relation rows with a leading key
+	relational := Graph[uint32, testRelations]{1: {2: {Stars: 1}}}
+	buf.Reset()
+	WriteRelationEdgeList[uint32, uint32](&buf, relational)
+	global := buf.String()
+	buf.Reset()
+	if err := WritePartitionedRelationEdgeList[string, uint32, uint32](&buf, map[string]Graph[uint32, testRelations]{"r": relational}); err != nil || buf.String() != "r "+global || global != "1 2 star 1\n" {
+		t.Errorf("unexpected partitioned relation rows %q %v", buf.String(), err)
+	}
+
+	buf.Reset()
+	if err := WriteSortedEdgeList(&buf, g); err != nil || buf.String() != "1 2 3\n1 4 5\n6 1 1\n" {
+		t.Errorf("sorted edge list %q %v", buf.String(), err)
+	}
+
+	// failures are returned instead of printed
+	missing := filepath.Join(t.TempDir(), "missing", "graph.txt")
+	if err := NeighborOutputGraph(missing, g); err == nil {
+		t.Error("expected an error writing into a missing directory")
+	}
+	if _, err := ReadNeighborGraph[uint32](missing); err == nil {
+		t.Error("expected an error reading a missing file")
+	}
+}
+
+// The writer before sinks, a Fprintf per edge straight into the file.
+func unbufferedEdgeList(outputFile string, graph Graph[uint32, uint32]) error {
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	for src, neighbors := range graph {
+		for target, weight := range neighbors {
+			if _, err := fmt.Fprintf(file, "%v %v %v\n", src, target, weight); err != nil {
+				return err
+			}
+		}
+	}
+	return file.Close()
+}
+
+func benchmarkEdgeList(b *testing.B, ext string, write func(string, Graph[uint32, uint32]) error) {
+	g := benchmarkGraph(1000, 50)
+	filename := filepath.Join(b.TempDir(), "edges.txt"+ext)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := write(filename, g); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StopTimer()
+	if info, err := os.Stat(filename); err == nil {
+		b.ReportMetric(float64(info.Size()), "bytes")
+	}
+}
+
+func BenchmarkEdgeListUnbuffered(b *testing.B) {
+	benchmarkEdgeList(b, "", unbufferedEdgeList)
+}
+
+func BenchmarkEdgeListSink(b *testing.B) {
+	benchmarkEdgeList(b, "", EdgeListOutputGraph[uint32, uint32])
+}
+
+func BenchmarkEdgeListSinkGzip(b *testing.B) {
+	benchmarkEdgeList(b, ".gz", EdgeListOutputGraph[uint32, uint32])
+}
+
+func BenchmarkEdgeListSinkZstd(b *testing.B) {
+	benchmarkEdgeList(b, ".zst", EdgeListOutputGraph[uint32, uint32])
+}
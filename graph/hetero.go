@@ -0,0 +1,229 @@
+package graph
+
+/*
+Implements a heterogeneous graph, where nodes have kinds and edges have relations.
+
+A plain Graph cannot tell user 42 from repo 42, so the other graphs rely on positional
+conventions (sources are users, targets are repos). HeteroGraph holds both explicitly,
+which allows mixing users, repos, orgs and issues, and several relations, in one graph.
+*/
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type NodeKind uint8
+
+const (
+	UserNode NodeKind = iota
+	RepoNode
+	OrgNode
+	IssueNode
+	AuthorNode // commit authors, identified by email hash
+	NumNodeKinds
+)
+
+var nodeKindNames = [NumNodeKinds]string{"user", "repo", "org", "issue", "author"}
+
+func (k NodeKind) String() string {
+	if k >= NumNodeKinds {
+		return "kind" + strconv.Itoa(int(k))
+	}
+	return nodeKindNames[k]
+}
+
+func ParseNodeKind(s string) (NodeKind, error) {
+	for i, name := range nodeKindNames {
+		if name == s {
+			return NodeKind(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown node kind %q", s)
+}
+
+// A node of a heterogeneous graph. ids are uint64 to hold any of the id types.
+type Node struct {
+	Kind NodeKind
+	ID   uint64
+}
+
+// Formats the node as kind:id, for example user:42.
+func (n Node) String() string {
+	return n.Kind.String() + ":" + strconv.FormatUint(n.ID, 10)
+}
+
+func ParseNode(s string) (Node, error) {
+	kindName, idPart, ok := strings.Cut(s, ":")
+	if !ok {
+		return Node{}, fmt.Errorf("node %q is not of the form kind:id", s)
+	}
+	kind, err := ParseNodeKind(kindName)
+	if err != nil {
+		return Node{}, err
+	}
+	id, err := strconv.ParseUint(idPart, 10, 64)
+	if err != nil {
+		return Node{}, fmt.Errorf("node %q: %w", s, err)
+	}
+	return Node{Kind: kind, ID: id}, nil
+}
+
+// The target of an edge and its relation, two nodes may have an edge per relation.
+type HeteroEdge struct {
+	Target   Node
+	Relation string
+}
+
+// A heterogeneous graph of src -> (target, relation) -> weight.
+type HeteroGraph[U any] map[Node]map[HeteroEdge]U
+
+// Sets the weight of the edge src -> target of the given relation.
+func (g HeteroGraph[U]) Set(src, target Node, relation string, weight U) {
+	if g[src] == nil {
+		g[src] = make(map[HeteroEdge]U)
+	}
+	g[src][HeteroEdge{Target: target, Relation: relation}] = weight
+}
+
+func (g HeteroGraph[U]) Get(src, target Node, relation string) (U, bool) {
+	weight, ok := g[src][HeteroEdge{Target: target, Relation: relation}]
+	return weight, ok
+}
+
+// The number of nodes of each kind, counting both sources and targets.
+func (g HeteroGraph[U]) Kinds() map[NodeKind]int {
+	seen := make(map[Node]struct{})
+	for src, edges := range g {
+		seen[src] = struct{}{}
+		for edge := range edges {
+			seen[edge.Target] = struct{}{}
+		}
+	}
+	kinds := make(map[NodeKind]int)
+	for node := range seen {
+		kinds[node.Kind]++
+	}
+	return kinds
+}
+
+/*
+Adds a plain graph into a heterogeneous graph, with its sources of srcKind and its
+targets of targetKind, all edges under the given relation. the ids are widened to uint64.
+*/
+func AddGraph[T Integer, U any](hetero HeteroGraph[U], graph Graph[T, U], srcKind, targetKind NodeKind, relation string) {
+	for src, neighbors := range graph {
+		for target, weight := range neighbors {
+			hetero.Set(Node{srcKind, uint64(src)}, Node{targetKind, uint64(target)}, relation, weight)
+		}
+	}
+}
+
+// Converts a plain graph to a heterogeneous one, see AddGraph.
+func FromGraph[T Integer, U any](graph Graph[T, U], srcKind, targetKind NodeKind, relation string) HeteroGraph[U] {
+	hetero := make(HeteroGraph[U], len(graph))
+	AddGraph(hetero, graph, srcKind, targetKind, relation)
+	return hetero
+}
+
+/*
+Converts a multi relational graph (see Relational) to a heterogeneous one,
+with an edge for every relation of every edge.
+*/
+func FromRelationalGraph[T Integer, W any, U Relational[W]](graph Graph[T, U], srcKind, targetKind NodeKind) HeteroGraph[W] {
+	hetero := make(HeteroGraph[W], len(graph))
+	for src, neighbors := range graph {
+		for target, relations := range neighbors {
+			relations.Relations(func(relation string, weight W) {
+				hetero.Set(Node{srcKind, uint64(src)}, Node{targetKind, uint64(target)}, relation, weight)
+			})
+		}
+	}
+	return hetero
+}
+
+/*
+Projects the edges of a single relation between two kinds back into a plain graph.
+*/
+func (g HeteroGraph[U]) Project(srcKind, targetKind NodeKind, relation string) Graph[uint64, U] {
+	graph := make(Graph[uint64, U])
+	for src, edges := range g {
+		if src.Kind != srcKind {
+			continue
+		}
+		for edge, weight := range edges {
+			if edge.Target.Kind != targetKind || edge.Relation != relation {
+				continue
+			}
+			if graph[src.ID] == nil {
+				graph[src.ID] = make(map[uint64]U)
+			}
+			graph[src.ID][edge.Target.ID] = weight
+		}
+	}
+	return graph
+}
+
+/*
+Outputs a heterogeneous graph in the edge-list format with kinds and relations,
+srcKind:src targetKind:target relation weight.
+*/
+func HeteroEdgeListOutputGraph[U any](outputFile string, graph HeteroGraph[U]) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return WriteHeteroEdgeList(w, graph)
+	})
+}
+
+// Writes a heterogeneous graph in the format of HeteroEdgeListOutputGraph.
+func WriteHeteroEdgeList[U any](w io.Writer, graph HeteroGraph[U]) error {
+	writer := BufferedWriter(w)
+	for src, edges := range graph {
+		for edge, weight := range edges {
+			if _, err := fmt.Fprintf(writer, "%v %v %v %v\n", src, edge.Target, edge.Relation, weight); err != nil {
+				return err
+			}
+		}
+	}
+	return writer.Flush()
+}
+
+/*
+Outputs the nodes of a heterogeneous graph, in the format kind:id kind label, where
+labels are given per kind (kinds with no labeler are labeled by their id).
+*/
+func HeteroNodeOutputGraph[U any](outputFile string, graph HeteroGraph[U], labels map[NodeKind]Labeler[uint64]) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return WriteHeteroNodes(w, graph, labels)
+	})
+}
+
+// Writes the nodes of a heterogeneous graph in the format of HeteroNodeOutputGraph.
+func WriteHeteroNodes[U any](w io.Writer, graph HeteroGraph[U], labels map[NodeKind]Labeler[uint64]) error {
+	writer := BufferedWriter(w)
+	seen := make(map[Node]struct{})
+	write := func(node Node) error {
+		if _, ok := seen[node]; ok {
+			return nil
+		}
+		seen[node] = struct{}{}
+		label := strconv.FormatUint(node.ID, 10)
+		if labeler, ok := labels[node.Kind]; ok && labeler != nil {
+			label = labeler(node.ID)
+		}
+		_, err := fmt.Fprintf(writer, "%v %v %v\n", node, node.Kind, label)
+		return err
+	}
+	for src, edges := range graph {
+		if err := write(src); err != nil {
+			return err
+		}
+		for edge := range edges {
+			if err := write(edge.Target); err != nil {
+				return err
+			}
+		}
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,385 @@
+package graph
+
+/*
+Implements outputs in the interchange formats of graph analytics tools:
+
+	- Matrix Market (.mtx), read by GraphBLAS, scipy and most sparse matrix libraries.
+	- METIS, read by the METIS and KaHIP partitioners.
+	- SNAP edge lists, with the header comments of the SNAP datasets.
+	- Pajek (.net), read by Pajek, networkx and igraph.
+
+These formats identify nodes by dense indices, so ids are remapped (see Remapper) in
+ascending order, sources before targets, and the mapping is written to RemapFile(outputFile).
+When the kinds differ (users and repos) both share one index space, targets after sources,
+except in Matrix Market where sources are the rows and targets the columns.
+*/
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Dense indices of a graph's nodes, in a single index space starting at 0.
+type denseIndex[T Integer] struct {
+	remapper   *Remapper[T]
+	srcKind    NodeKind
+	targetKind NodeKind
+	offset     uint32 // added to target indices
+}
+
+func newDenseIndex[T Integer, U any](graph Graph[T, U], srcKind, targetKind NodeKind) *denseIndex[T] {
+	r := NewRemapper[T]()
+	srcs := make([]T, 0, len(graph))
+	targets := make(map[T]struct{})
+	for src, neighbors := range graph {
+		srcs = append(srcs, src)
+		for target := range neighbors {
+			targets[target] = struct{}{}
+		}
+	}
+	slices.Sort(srcs)
+	for _, src := range srcs {
+		r.Dense(srcKind, src)
+	}
+	sortedTargets := make([]T, 0, len(targets))
+	for target := range targets {
+		sortedTargets = append(sortedTargets, target)
+	}
+	slices.Sort(sortedTargets)
+	for _, target := range sortedTargets {
+		r.Dense(targetKind, target)
+	}
+
+	index := &denseIndex[T]{remapper: r, srcKind: srcKind, targetKind: targetKind}
+	if srcKind != targetKind {
+		index.offset = uint32(r.Len(srcKind))
+	}
+	return index
+}
+
+// The number of nodes in the index space.
+func (d *denseIndex[T]) len() int {
+	if d.srcKind == d.targetKind {
+		return d.remapper.Len(d.srcKind)
+	}
+	return d.remapper.Len(d.srcKind) + d.remapper.Len(d.targetKind)
+}
+
+// The kind and original id of an index.
+func (d *denseIndex[T]) node(i uint32) (NodeKind, T) {
+	if d.srcKind != d.targetKind && i >= d.offset {
+		id, _ := d.remapper.Sparse(d.targetKind, i-d.offset)
+		return d.targetKind, id
+	}
+	id, _ := d.remapper.Sparse(d.srcKind, i)
+	return d.srcKind, id
+}
+
+// The graph over the indices, sorted by source and target.
+func denseCSR[T Integer, U any](d *denseIndex[T], graph Graph[T, U]) *CSR[uint32, U] {
+	builder := NewCSRBuilder[uint32, U](true)
+	for src, neighbors := range graph {
+		denseSrc, _ := d.remapper.Lookup(d.srcKind, src)
+		for target, weight := range neighbors {
+			denseTarget, _ := d.remapper.Lookup(d.targetKind, target)
+			builder.AddEdge(denseSrc, denseTarget+d.offset, weight)
+		}
+	}
+	return builder.Build()
+}
+
+/*
+Writes outputFile by write, which gets the base name of the file for its comments, and
+persists the mapping it returns into RemapFile(outputFile).
+*/
+func writeInterchangeFile[T Integer](outputFile string, write func(w io.Writer, name string) (*Remapper[T], error)) error {
+	var r *Remapper[T]
+	err := writeFile(outputFile, func(w io.Writer) (err error) {
+		r, err = write(w, filepath.Base(outputFile))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return WriteRemapper(RemapFile(outputFile), r)
+}
+
+// Passes a buffered writer over out to write, returning the mapping of d.
+func writeInterchange[T Integer](out io.Writer, d *denseIndex[T], write func(w *bufio.Writer) error) (*Remapper[T], error) {
+	w := BufferedWriter(out)
+	if err := write(w); err != nil {
+		return nil, err
+	}
+	return d.remapper, w.Flush()
+}
+
+// The comment mentioning the mapping file of the file name, if known.
+func mappingComment(name string) string {
+	if name == "" {
+		return ""
+	}
+	return ", ids in " + RemapFile(name)
+}
+
+/*
+Outputs a graph as a Matrix Market coordinate matrix, sources of srcKind as rows and targets
+of targetKind as columns, 1 indexed. numeric weights give an integer or real matrix,
+other weights a pattern matrix.
+*/
+func WriteMatrixMarket[T Integer, U any](outputFile string, graph Graph[T, U], srcKind, targetKind NodeKind) error {
+	return writeInterchangeFile(outputFile, func(w io.Writer, name string) (*Remapper[T], error) {
+		return writeMatrixMarket(w, name, graph, srcKind, targetKind)
+	})
+}
+
+// Writes a graph as a Matrix Market matrix, see WriteMatrixMarket, returning the mapping of its ids.
+func WriteMatrixMarketTo[T Integer, U any](w io.Writer, graph Graph[T, U], srcKind, targetKind NodeKind) (*Remapper[T], error) {
+	return writeMatrixMarket(w, "", graph, srcKind, targetKind)
+}
+
+func writeMatrixMarket[T Integer, U any](out io.Writer, name string, graph Graph[T, U], srcKind, targetKind NodeKind) (*Remapper[T], error) {
+	d := newDenseIndex(graph, srcKind, targetKind)
+	d.offset = 0 // rows and columns are indexed separately
+	csr := denseCSR(d, graph)
+
+	field := "pattern"
+	switch kind, _ := weightKindOf[U](); kind {
+	case weightUnsigned, weightSigned:
+		field = "integer"
+	case weightFloat:
+		field = "real"
+	}
+	return writeInterchange(out, d, func(w *bufio.Writer) error {
+		fmt.Fprintf(w, "%%%%MatrixMarket matrix coordinate %s general\n", field)
+		fmt.Fprintf(w, "%% rows are %v nodes, columns are %v nodes%s\n", srcKind, targetKind, mappingComment(name))
+		fmt.Fprintf(w, "%d %d %d\n", d.remapper.Len(srcKind), d.remapper.Len(targetKind), csr.NumEdges())
+
+		var err error
+		csr.ForEachEdge(func(src, target uint32, weight U) {
+			if err != nil {
+				return
+			}
+			if field == "pattern" {
+				_, err = fmt.Fprintf(w, "%d %d\n", src+1, target+1)
+			} else {
+				_, err = fmt.Fprintf(w, "%d %d %v\n", src+1, target+1, weight)
+			}
+		})
+		return err
+	})
+}
+
+/*
+Outputs a graph as a SNAP edge list, tab separated 0 indexed node pairs (and weights, when numeric),
+under the header comments of the SNAP datasets.
+*/
+func WriteSNAP[T Integer, U any](outputFile string, graph Graph[T, U], srcKind, targetKind NodeKind, description string) error {
+	return writeInterchangeFile(outputFile, func(w io.Writer, name string) (*Remapper[T], error) {
+		return writeSNAP(w, name, graph, srcKind, targetKind, description)
+	})
+}
+
+// Writes a graph as a SNAP edge list, see WriteSNAP, returning the mapping of its ids.
+func WriteSNAPTo[T Integer, U any](w io.Writer, graph Graph[T, U], srcKind, targetKind NodeKind, description string) (*Remapper[T], error) {
+	return writeSNAP(w, "", graph, srcKind, targetKind, description)
+}
+
+func writeSNAP[T Integer, U any](out io.Writer, name string, graph Graph[T, U], srcKind, targetKind NodeKind, description string) (*Remapper[T], error) {
+	d := newDenseIndex(graph, srcKind, targetKind)
+	csr := denseCSR(d, graph)
+	kind, _ := weightKindOf[U]()
+
+	return writeInterchange(out, d, func(w *bufio.Writer) error {
+		if name != "" {
+			fmt.Fprintf(w, "# Directed graph (each unordered pair of nodes is saved once): %s\n", name)
+		} else {
+			fmt.Fprintf(w, "# Directed graph (each unordered pair of nodes is saved once)\n")
+		}
+		if description != "" {
+			fmt.Fprintf(w, "# %s\n", description)
+		}
+		if srcKind != targetKind {
+			fmt.Fprintf(w, "# Nodes 0-%d are %v nodes, the rest %v nodes%s\n", d.offset-1, srcKind, targetKind, mappingComment(name))
+		}
+		fmt.Fprintf(w, "# Nodes: %d Edges: %d\n", d.len(), csr.NumEdges())
+		if kind != weightNone {
+			fmt.Fprintf(w, "# FromNodeId\tToNodeId\tWeight\n")
+		} else {
+			fmt.Fprintf(w, "# FromNodeId\tToNodeId\n")
+		}
+
+		var err error
+		csr.ForEachEdge(func(src, target uint32, weight U) {
+			if err != nil {
+				return
+			}
+			if kind != weightNone {
+				_, err = fmt.Fprintf(w, "%d\t%d\t%v\n", src, target, weight)
+			} else {
+				_, err = fmt.Fprintf(w, "%d\t%d\n", src, target)
+			}
+		})
+		return err
+	})
+}
+
+/*
+Outputs a graph in the Pajek format, 1 indexed, labeling vertices by labeler (or by their id when nil).
+graphs between two kinds are written as two mode networks, sources first.
+numeric weights are written as arc weights.
+*/
+func WritePajek[T Integer, U any](outputFile string, graph Graph[T, U], srcKind, targetKind NodeKind, srcLabel, targetLabel Labeler[T]) error {
+	return writeInterchangeFile(outputFile, func(w io.Writer, name string) (*Remapper[T], error) {
+		return writePajek(w, name, graph, srcKind, targetKind, srcLabel, targetLabel)
+	})
+}
+
+// Writes a graph in the Pajek format, see WritePajek, returning the mapping of its ids.
+func WritePajekTo[T Integer, U any](w io.Writer, graph Graph[T, U], srcKind, targetKind NodeKind, srcLabel, targetLabel Labeler[T]) (*Remapper[T], error) {
+	return writePajek(w, "", graph, srcKind, targetKind, srcLabel, targetLabel)
+}
+
+func writePajek[T Integer, U any](out io.Writer, name string, graph Graph[T, U], srcKind, targetKind NodeKind, srcLabel, targetLabel Labeler[T]) (*Remapper[T], error) {
+	d := newDenseIndex(graph, srcKind, targetKind)
+	csr := denseCSR(d, graph)
+	kind, _ := weightKindOf[U]()
+
+	return writeInterchange(out, d, func(w *bufio.Writer) error {
+		if srcKind != targetKind {
+			fmt.Fprintf(w, "*Vertices %d %d\n", d.len(), d.offset)
+		} else {
+			fmt.Fprintf(w, "*Vertices %d\n", d.len())
+		}
+		for i := 0; i < d.len(); i++ {
+			nodeKind, id := d.node(uint32(i))
+			label := fmt.Sprint(id)
+			if nodeKind == srcKind && srcLabel != nil {
+				label = srcLabel(id)
+			} else if nodeKind == targetKind && targetLabel != nil {
+				label = targetLabel(id)
+			}
+			// pajek has no escapes within quoted labels
+			if _, err := fmt.Fprintf(w, "%d \"%s\"\n", i+1, strings.ReplaceAll(label, "\"", "'")); err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(w, "*Arcs\n")
+		var err error
+		csr.ForEachEdge(func(src, target uint32, weight U) {
+			if err != nil {
+				return
+			}
+			if kind != weightNone {
+				_, err = fmt.Fprintf(w, "%d %d %v\n", src+1, target+1, weight)
+			} else {
+				_, err = fmt.Fprintf(w, "%d %d\n", src+1, target+1)
+			}
+		})
+		return err
+	})
+}
+
+/*
+Outputs a graph in the METIS format, which is undirected: every edge is written from both
+of its nodes, self loops are dropped and edges in both directions are merged.
+*/
+func WriteMETIS[T Integer, U any](outputFile string, graph Graph[T, U], srcKind, targetKind NodeKind) error {
+	return writeInterchangeFile(outputFile, func(w io.Writer, name string) (*Remapper[T], error) {
+		return WriteMETISTo(w, graph, srcKind, targetKind)
+	})
+}
+
+// Writes a graph in the METIS format, see WriteMETIS, returning the mapping of its ids.
+func WriteMETISTo[T Integer, U any](w io.Writer, graph Graph[T, U], srcKind, targetKind NodeKind) (*Remapper[T], error) {
+	d := newDenseIndex(graph, srcKind, targetKind)
+	return writeMETIS(w, d, denseCSR(d, graph), func(U) uint64 { return 1 }, false)
+}
+
+/*
+Same as WriteMETIS, with the weights written as METIS edge weights. weights of edges
+in both directions are summed.
+*/
+func WriteWeightedMETIS[T Integer, W Integer](outputFile string, graph Graph[T, W], srcKind, targetKind NodeKind) error {
+	return writeInterchangeFile(outputFile, func(w io.Writer, name string) (*Remapper[T], error) {
+		return WriteWeightedMETISTo(w, graph, srcKind, targetKind)
+	})
+}
+
+/*
+Writes a weighted graph in the METIS format, see WriteWeightedMETIS, returning the mapping of its ids.
+METIS edge weights must be positive, so a zero or negative weight is an error (self loops are dropped).
+*/
+func WriteWeightedMETISTo[T Integer, W Integer](w io.Writer, graph Graph[T, W], srcKind, targetKind NodeKind) (*Remapper[T], error) {
+	for src, neighbors := range graph {
+		for target, weight := range neighbors {
+			if weight <= 0 && src != target {
+				return nil, fmt.Errorf("metis edge weights must be positive, edge %v %v has weight %v", src, target, weight)
+			}
+		}
+	}
+	d := newDenseIndex(graph, srcKind, targetKind)
+	return writeMETIS(w, d, denseCSR(d, graph), func(w W) uint64 { return uint64(w) }, true)
+}
+
+func writeMETIS[T Integer, U any](out io.Writer, d *denseIndex[T], csr *CSR[uint32, U], weightOf func(U) uint64, weighted bool) (*Remapper[T], error) {
+	// Each undirected edge once, from its smaller node, with the weights of both directions summed.
+	edges := make([]csrEdge[uint32, uint64], 0, csr.NumEdges())
+	csr.ForEachEdge(func(src, target uint32, weight U) {
+		if src != target {
+			edges = append(edges, csrEdge[uint32, uint64]{min(src, target), max(src, target), weightOf(weight)})
+		}
+	})
+	slices.SortFunc(edges, func(x, y csrEdge[uint32, uint64]) int {
+		if c := cmp.Compare(x.src, y.src); c != 0 {
+			return c
+		}
+		return cmp.Compare(x.dst, y.dst)
+	})
+	merged := edges[:0]
+	for _, edge := range edges {
+		if n := len(merged); n > 0 && merged[n-1].src == edge.src && merged[n-1].dst == edge.dst {
+			merged[n-1].weight += edge.weight
+			continue
+		}
+		merged = append(merged, edge)
+	}
+
+	builder := NewCSRBuilder[uint32, uint64](weighted)
+	for _, edge := range merged {
+		builder.AddEdge(edge.src, edge.dst, edge.weight)
+		builder.AddEdge(edge.dst, edge.src, edge.weight)
+	}
+	symmetric := builder.Build()
+
+	return writeInterchange(out, d, func(w *bufio.Writer) error {
+		if weighted {
+			fmt.Fprintf(w, "%d %d 001\n", d.len(), len(merged))
+		} else {
+			fmt.Fprintf(w, "%d %d\n", d.len(), len(merged))
+		}
+		for i := 0; i < d.len(); i++ {
+			neighbors := symmetric.Neighbors(uint32(i))
+			weights := symmetric.Weights(uint32(i))
+			for j, neighbor := range neighbors {
+				if j > 0 {
+					w.WriteByte(' ')
+				}
+				if weighted {
+					fmt.Fprintf(w, "%d %d", neighbor+1, weights[j])
+				} else {
+					fmt.Fprintf(w, "%d", neighbor+1)
+				}
+			}
+			if err := w.WriteByte('\n'); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
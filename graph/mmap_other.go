@@ -0,0 +1,19 @@
+//go:build !unix
+
+package graph
+
+import (
+	"os"
+)
+
+func mapFile(filename string) ([]byte, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return alignedCopy(data), nil
+}
+
+func unmapFile(data []byte) error {
+	return nil
+}
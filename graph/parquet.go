@@ -0,0 +1,203 @@
+package graph
+
+/*
+Implements Parquet and Arrow IPC (see arrow.go) output of graph edges, for loading graphs
+into a data warehouse.
+
+Rows are written as they go through the graph, in row groups, using a pure Go Parquet
+implementation. Ids are uint64 to hold any of the id types, numeric weights are stored
+as doubles (1 for unweighted graphs), and type and timestamp are null unless given.
+*/
+
+import (
+	"os"
+	"reflect"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// A row of a Parquet or Arrow edge file.
+type ParquetEdge struct {
+	Src       uint64  `parquet:"src,delta" arrow:"src"`
+	Dst       uint64  `parquet:"dst" arrow:"dst"`
+	Weight    float64 `parquet:"weight" arrow:"weight"`
+	Type      *string `parquet:"type,optional,dict" arrow:"type"`                                       // the relation of the edge, see Relational
+	Timestamp *int64  `parquet:"timestamp,optional,timestamp(millisecond)" arrow:"timestamp,timestamp"` // unix milliseconds
+}
+
+const parquetBatch = 4096
+
+// Writes ParquetEdge rows into a file, batching them into the underlying writer.
+type ParquetEdgeWriter struct {
+	file   *os.File
+	writer *parquet.GenericWriter[ParquetEdge]
+	batch  []ParquetEdge
+}
+
+func NewParquetEdgeWriter(filename string) (*ParquetEdgeWriter, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &ParquetEdgeWriter{
+		file:   file,
+		writer: parquet.NewGenericWriter[ParquetEdge](file, parquet.Compression(&parquet.Zstd)),
+		batch:  make([]ParquetEdge, 0, parquetBatch),
+	}, nil
+}
+
+func (w *ParquetEdgeWriter) Write(edge ParquetEdge) error {
+	w.batch = append(w.batch, edge)
+	if len(w.batch) == parquetBatch {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *ParquetEdgeWriter) flush() error {
+	_, err := w.writer.Write(w.batch)
+	w.batch = w.batch[:0]
+	return err
+}
+
+// Writes the remaining rows and the file footer, and closes the file.
+func (w *ParquetEdgeWriter) Close() error {
+	if err := w.flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.writer.Close(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// The value of a numeric weight, by its underlying type so that named numbers count, ok is false for any other type.
+func numberOf[U any](weight U) (float64, bool) {
+	value := reflect.ValueOf(&weight).Elem()
+	switch {
+	case value.CanUint():
+		return float64(value.Uint()), true
+	case value.CanInt():
+		return float64(value.Int()), true
+	case value.CanFloat():
+		return value.Float(), true
+	}
+	return 0, false
+}
+
+// Writes edge rows, see ParquetEdgeWriter and ArrowFile.
+type edgeRowWriter interface {
+	Write(edge ParquetEdge) error
+	Close() error
+}
+
+// Writes the edges of a graph as rows. non numeric weights (and struct{}) are written as 1.
+func writeEdgeRows[T Integer, U any](writer edgeRowWriter, graph Graph[T, U]) error {
+	for src, neighbors := range graph {
+		for target, weight := range neighbors {
+			value, ok := numberOf(weight)
+			if !ok {
+				value = 1
+			}
+			if err := writer.Write(ParquetEdge{Src: uint64(src), Dst: uint64(target), Weight: value}); err != nil {
+				writer.Close()
+				return err
+			}
+		}
+	}
+	return writer.Close()
+}
+
+// Writes the edges of a multi relational graph as rows, a row per relation with the relation as type.
+func writeRelationEdgeRows[T Integer, W Number, U Relational[W]](writer edgeRowWriter, graph Graph[T, U]) error {
+	var err error
+	for src, neighbors := range graph {
+		for target, relations := range neighbors {
+			relations.Relations(func(relation string, weight W) {
+				if err == nil {
+					err = writer.Write(ParquetEdge{Src: uint64(src), Dst: uint64(target), Weight: float64(weight), Type: &relation})
+				}
+			})
+			if err != nil {
+				writer.Close()
+				return err
+			}
+		}
+	}
+	return writer.Close()
+}
+
+// Writes the edges of a temporal graph as rows, weighted by count, with the last time of the edge as timestamp.
+func writeTemporalEdgeRows[T Integer](writer edgeRowWriter, graph Graph[T, TemporalEdge]) error {
+	for src, neighbors := range graph {
+		for target, edge := range neighbors {
+			last := edge.Last * 1000
+			if err := writer.Write(ParquetEdge{Src: uint64(src), Dst: uint64(target), Weight: float64(edge.Count), Timestamp: &last}); err != nil {
+				writer.Close()
+				return err
+			}
+		}
+	}
+	return writer.Close()
+}
+
+// Outputs a graph as Parquet edges. non numeric weights (and struct{}) are written as 1.
+func WriteParquetEdges[T Integer, U any](outputFile string, graph Graph[T, U]) error {
+	writer, err := NewParquetEdgeWriter(outputFile)
+	if err != nil {
+		return err
+	}
+	return writeEdgeRows(writer, graph)
+}
+
+// Outputs a multi relational graph as Parquet edges, a row per relation with the relation as type.
+func WriteRelationParquetEdges[T Integer, W Number, U Relational[W]](outputFile string, graph Graph[T, U]) error {
+	writer, err := NewParquetEdgeWriter(outputFile)
+	if err != nil {
+		return err
+	}
+	return writeRelationEdgeRows[T, W](writer, graph)
+}
+
+// Outputs a temporal graph as Parquet edges, weighted by count, with the last time of the edge as timestamp.
+func WriteTemporalParquetEdges[T Integer](outputFile string, graph Graph[T, TemporalEdge]) error {
+	writer, err := NewParquetEdgeWriter(outputFile)
+	if err != nil {
+		return err
+	}
+	return writeTemporalEdgeRows(writer, graph)
+}
+
+// Outputs a graph as Arrow edges, in the columns of ParquetEdge, see CreateArrowFile and WriteParquetEdges.
+func WriteArrowEdges[T Integer, U any](outputFile string, graph Graph[T, U]) error {
+	writer, err := CreateArrowFile[ParquetEdge](outputFile)
+	if err != nil {
+		return err
+	}
+	return writeEdgeRows(writer, graph)
+}
+
+// Outputs a multi relational graph as Arrow edges, see WriteRelationParquetEdges.
+func WriteRelationArrowEdges[T Integer, W Number, U Relational[W]](outputFile string, graph Graph[T, U]) error {
+	writer, err := CreateArrowFile[ParquetEdge](outputFile)
+	if err != nil {
+		return err
+	}
+	return writeRelationEdgeRows[T, W](writer, graph)
+}
+
+// Outputs a temporal graph as Arrow edges, see WriteTemporalParquetEdges.
+func WriteTemporalArrowEdges[T Integer](outputFile string, graph Graph[T, TemporalEdge]) error {
+	writer, err := CreateArrowFile[ParquetEdge](outputFile)
+	if err != nil {
+		return err
+	}
+	return writeTemporalEdgeRows(writer, graph)
+}
+
+// Reads the rows of a Parquet edge file.
+func ReadParquetEdges(filename string) ([]ParquetEdge, error) {
+	return parquet.ReadFile[ParquetEdge](filename)
+}
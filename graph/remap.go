@@ -0,0 +1,385 @@
+package graph
+
+/*
+Implements remapping of sparse ids (GitHub ids) to dense indices 0..N-1, per node kind.
+
+Dense indices allow array indexed algorithms (node attributes in slices rather than
+maps), and small indices pack better in varint and uint32 encodings. The mapping is
+persisted next to the graph file (see RemapFile).
+
+Remapping is opt-in on write, through the *Remapped writers, NewRemappedCSRBuilder and
+the -dense flag. Reading text files is transparent: ReadNeighborGraph and ReadEdgeList
+restore the original ids when a mapping written by a *Remapped writer is next to the
+file, and the other writers remove any mapping left next to their output. Binary files hold uint32 indices whatever the id type, so they are read back with
+ReadNeighborGraphBinaryRemapped.
+*/
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// The bidirectional mapping of a single node kind.
+type idMap[T Integer] struct {
+	toDense  map[T]uint32
+	toSparse []T
+}
+
+type Remapper[T Integer] struct {
+	kinds [NumNodeKinds]*idMap[T]
+	// The kinds of the sources and targets of the graph file the mapping was read next to,
+	// NumNodeKinds when the mapping belongs to no such graph.
+	srcKind, targetKind NodeKind
+}
+
+func NewRemapper[T Integer]() *Remapper[T] {
+	return &Remapper[T]{srcKind: NumNodeKinds, targetKind: NumNodeKinds}
+}
+
+func (r *Remapper[T]) kind(kind NodeKind) *idMap[T] {
+	if r.kinds[kind] == nil {
+		r.kinds[kind] = &idMap[T]{toDense: make(map[T]uint32)}
+	}
+	return r.kinds[kind]
+}
+
+// The dense index of id, assigning the next free index to ids not seen before.
+func (r *Remapper[T]) Dense(kind NodeKind, id T) uint32 {
+	m := r.kind(kind)
+	dense, ok := m.toDense[id]
+	if !ok {
+		dense = uint32(len(m.toSparse))
+		m.toDense[id] = dense
+		m.toSparse = append(m.toSparse, id)
+	}
+	return dense
+}
+
+// The dense index of id, without assigning one.
+func (r *Remapper[T]) Lookup(kind NodeKind, id T) (uint32, bool) {
+	dense, ok := r.kind(kind).toDense[id]
+	return dense, ok
+}
+
+// The original id of a dense index, ok is false for indices that were not assigned.
+func (r *Remapper[T]) Sparse(kind NodeKind, dense uint32) (T, bool) {
+	m := r.kind(kind)
+	if int(dense) >= len(m.toSparse) {
+		var zero T
+		return zero, false
+	}
+	return m.toSparse[dense], true
+}
+
+// The number of indices assigned for the kind.
+func (r *Remapper[T]) Len(kind NodeKind) int {
+	return len(r.kind(kind).toSparse)
+}
+
+/*
+Remaps a graph to dense indices, its sources of srcKind and its targets of targetKind.
+the same remapper should be used for all graphs sharing nodes. new indices are assigned
+in sorted order of sources and then of their targets, so that remapping is deterministic.
+*/
+func Remap[T Integer, U any](r *Remapper[T], graph Graph[T, U], srcKind, targetKind NodeKind) Graph[uint32, U] {
+	dense := make(Graph[uint32, U], len(graph))
+	var targets []T
+	for _, src := range sortedKeys(graph, nil) {
+		neighbors := graph[src]
+		denseSrc := r.Dense(srcKind, src)
+		denseNeighbors := make(map[uint32]U, len(neighbors))
+		targets = sortedKeys(neighbors, targets)
+		for _, target := range targets {
+			denseNeighbors[r.Dense(targetKind, target)] = neighbors[target]
+		}
+		dense[denseSrc] = denseNeighbors
+	}
+	return dense
+}
+
+// Restores the original ids of a graph remapped by Remap. unassigned indices are an error.
+func Unmap[T Integer, U any](r *Remapper[T], dense Graph[uint32, U], srcKind, targetKind NodeKind) (Graph[T, U], error) {
+	graph := make(Graph[T, U], len(dense))
+	for denseSrc, neighbors := range dense {
+		src, ok := r.Sparse(srcKind, denseSrc)
+		if !ok {
+			return nil, fmt.Errorf("unknown %v index %d", srcKind, denseSrc)
+		}
+		sparseNeighbors := make(map[T]U, len(neighbors))
+		for denseTarget, weight := range neighbors {
+			target, ok := r.Sparse(targetKind, denseTarget)
+			if !ok {
+				return nil, fmt.Errorf("unknown %v index %d", targetKind, denseTarget)
+			}
+			sparseNeighbors[target] = weight
+		}
+		graph[src] = sparseNeighbors
+	}
+	return graph, nil
+}
+
+// The file the mapping of a graph file is persisted in.
+func RemapFile(graphFile string) string {
+	return graphFile + ".ids"
+}
+
+const remapMagic = "GHIDS"
+const remapVersion = 2
+
+// The most ids of a kind allocated upfront when reading a mapping, the rest grow as read.
+const remapPrealloc = 1 << 16
+
+/*
+Saves the mapping in the format
+
+	magic "GHIDS" | version | src kind | target kind | kind count | (kind | id count | id ...) ...
+
+where counts and ids are uvarints, and ids are in dense order. src and target kind are
+the kinds of the graph file the mapping belongs to, NumNodeKinds for none.
+*/
+func WriteRemapper[T Integer](filename string, r *Remapper[T]) error {
+	return writeRemapper(filename, r, r.srcKind, r.targetKind)
+}
+
+// Saves the mapping of a graph file written with sources of srcKind and targets of targetKind.
+func writeRemapper[T Integer](filename string, r *Remapper[T], srcKind, targetKind NodeKind) error {
+	sink, err := CreateSink(filename)
+	if err != nil {
+		return err
+	}
+	if err := writeRemapperTo(sink.Writer, r, srcKind, targetKind); err != nil {
+		sink.Close()
+		return fmt.Errorf("%v: %w", filename, err)
+	}
+	return sink.Close()
+}
+
+// Writes a mapping in the format of WriteRemapper.
+func WriteRemapperTo[T Integer](w io.Writer, r *Remapper[T]) error {
+	return writeRemapperTo(w, r, r.srcKind, r.targetKind)
+}
+
+func writeRemapperTo[T Integer](w io.Writer, r *Remapper[T], srcKind, targetKind NodeKind) error {
+	writer := BufferedWriter(w)
+	writer.WriteString(remapMagic)
+	writer.WriteByte(remapVersion)
+	writer.WriteByte(byte(srcKind))
+	writer.WriteByte(byte(targetKind))
+
+	kinds := make([]NodeKind, 0, NumNodeKinds)
+	for kind, m := range r.kinds {
+		if m != nil {
+			kinds = append(kinds, NodeKind(kind))
+		}
+	}
+	buf := make([]byte, binary.MaxVarintLen64)
+	writer.Write(buf[:binary.PutUvarint(buf, uint64(len(kinds)))])
+	for _, kind := range kinds {
+		ids := r.kinds[kind].toSparse
+		writer.WriteByte(byte(kind))
+		writer.Write(buf[:binary.PutUvarint(buf, uint64(len(ids)))])
+		for _, id := range ids {
+			if _, err := writer.Write(buf[:binary.PutUvarint(buf, uint64(id))]); err != nil {
+				return err
+			}
+		}
+	}
+	return writer.Flush()
+}
+
+func ReadRemapper[T Integer](filename string) (*Remapper[T], error) {
+	var r *Remapper[T]
+	err := readFile(filename, func(reader io.Reader) (err error) {
+		r, err = ReadRemapperFrom[T](reader)
+		return err
+	})
+	return r, err
+}
+
+/*
+Reads a mapping in the format of WriteRemapper. counts are not trusted: ids are
+allocated as they are read, and duplicate ids or ids overflowing T are an error.
+*/
+func ReadRemapperFrom[T Integer](r io.Reader) (*Remapper[T], error) {
+	reader := BufferedReader(r)
+	header := make([]byte, len(remapMagic)+3)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	if string(header[:len(remapMagic)]) != remapMagic || header[len(remapMagic)] != remapVersion {
+		return nil, fmt.Errorf("not a version %d id mapping file", remapVersion)
+	}
+
+	m := NewRemapper[T]()
+	m.srcKind, m.targetKind = NodeKind(header[len(remapMagic)+1]), NodeKind(header[len(remapMagic)+2])
+	if m.srcKind > NumNodeKinds || m.targetKind > NumNodeKinds || (m.srcKind == NumNodeKinds) != (m.targetKind == NumNodeKinds) {
+		return nil, fmt.Errorf("unknown graph node kinds %d and %d", m.srcKind, m.targetKind)
+	}
+	kinds, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if kinds > uint64(NumNodeKinds) {
+		return nil, fmt.Errorf("%d node kinds, at most %d", kinds, NumNodeKinds)
+	}
+	for i := uint64(0); i < kinds; i++ {
+		kind, err := reader.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if NodeKind(kind) >= NumNodeKinds {
+			return nil, fmt.Errorf("unknown node kind %d", kind)
+		}
+		if m.kinds[kind] != nil {
+			return nil, fmt.Errorf("node kind %v mapped twice", NodeKind(kind))
+		}
+		count, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, err
+		}
+		if count > math.MaxUint32+1 {
+			return nil, fmt.Errorf("%d %v ids do not fit uint32 indices", count, NodeKind(kind))
+		}
+		ids := m.kind(NodeKind(kind))
+		ids.toSparse = make([]T, 0, min(count, remapPrealloc))
+		for j := uint64(0); j < count; j++ {
+			raw, err := binary.ReadUvarint(reader)
+			if err != nil {
+				return nil, err
+			}
+			id := T(raw)
+			if uint64(id) != raw || id < 0 {
+				return nil, fmt.Errorf("%v id %d overflows %T", NodeKind(kind), raw, id)
+			}
+			if _, ok := ids.toDense[id]; ok {
+				return nil, fmt.Errorf("%v id %d mapped twice", NodeKind(kind), id)
+			}
+			ids.toDense[id] = uint32(j)
+			ids.toSparse = append(ids.toSparse, id)
+		}
+	}
+	return m, nil
+}
+
+/*
+Restores the original ids of a graph read from filename, when a mapping written by one
+of the *Remapped writers is next to it. other graphs are returned as they are.
+*/
+func restoreIds[T Integer, U any](filename string, graph Graph[T, U]) (Graph[T, U], error) {
+	if _, err := os.Stat(RemapFile(filename)); err != nil {
+		return graph, nil
+	}
+	r, err := ReadRemapper[T](RemapFile(filename))
+	if err != nil {
+		return nil, err
+	}
+	if r.srcKind == NumNodeKinds {
+		return graph, nil
+	}
+	dense := make(Graph[uint32, U], len(graph))
+	for src, neighbors := range graph {
+		if uint64(src) > math.MaxUint32 {
+			return nil, fmt.Errorf("%v: index %d is not dense", filename, src)
+		}
+		denseNeighbors := make(map[uint32]U, len(neighbors))
+		for target, weight := range neighbors {
+			if uint64(target) > math.MaxUint32 {
+				return nil, fmt.Errorf("%v: index %d is not dense", filename, target)
+			}
+			denseNeighbors[uint32(target)] = weight
+		}
+		dense[uint32(src)] = denseNeighbors
+	}
+	graph, err = Unmap(r, dense, r.srcKind, r.targetKind)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", filename, err)
+	}
+	return graph, nil
+}
+
+/*
+Same as WriteNeighborGraphBinary, but with nodes remapped to dense uint32 indices, and the
+mapping written to RemapFile(filename). as remapping is deterministic, the output is
+sorted by index, like WriteNeighborGraphBinarySorted.
+*/
+func WriteNeighborGraphBinaryRemapped[T Integer](filename string, graph map[T]map[T]struct{}, srcKind, targetKind NodeKind) error {
+	r := NewRemapper[T]()
+	dense := Remap(r, Graph[T, struct{}](graph), srcKind, targetKind)
+	if err := WriteNeighborGraphBinarySorted[uint32](filename, dense); err != nil {
+		return err
+	}
+	return writeRemapper(RemapFile(filename), r, srcKind, targetKind)
+}
+
+/*
+Reads a graph written by WriteNeighborGraphBinaryRemapped, restoring the original ids.
+unlike ReadNeighborGraphBinary, it also reads mappings that do not record the node kinds.
+*/
+func ReadNeighborGraphBinaryRemapped[T Integer](filename string, srcKind, targetKind NodeKind) (map[T]map[T]struct{}, error) {
+	r, err := ReadRemapper[T](RemapFile(filename))
+	if err != nil {
+		return nil, err
+	}
+	var dense map[uint32]map[uint32]struct{}
+	err = readFile(filename, func(r io.Reader) (err error) {
+		dense, err = ReadNeighborsBinary[uint32](r)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return Unmap(r, Graph[uint32, struct{}](dense), srcKind, targetKind)
+}
+
+/*
+Same as EdgeListOutputGraph, but with nodes remapped to dense indices, and the
+mapping written to RemapFile(outputFile). the output is sorted by index.
+*/
+func EdgeListOutputGraphRemapped[T Integer, U any](outputFile string, graph Graph[T, U], srcKind, targetKind NodeKind) error {
+	r := NewRemapper[T]()
+	if err := SortedEdgeListOutputGraph(outputFile, Remap(r, graph, srcKind, targetKind)); err != nil {
+		return err
+	}
+	return writeRemapper(RemapFile(outputFile), r, srcKind, targetKind)
+}
+
+/*
+Same as NeighborOutputGraph, but with nodes remapped to dense indices, and the
+mapping written to RemapFile(outputFile). the output is sorted by index.
+*/
+func NeighborOutputGraphRemapped[T Integer, U any](outputFile string, graph Graph[T, U], srcKind, targetKind NodeKind) error {
+	r := NewRemapper[T]()
+	if err := SortedNeighborOutputGraph(outputFile, Remap(r, graph, srcKind, targetKind)); err != nil {
+		return err
+	}
+	return writeRemapper(RemapFile(outputFile), r, srcKind, targetKind)
+}
+
+// Builds a CSR over dense indices, see NewCSR and Remap.
+func NewRemappedCSR[T Integer, U any](r *Remapper[T], graph Graph[T, U], srcKind, targetKind NodeKind, weighted bool) *CSR[uint32, U] {
+	return NewCSR(Remap(r, graph, srcKind, targetKind), weighted)
+}
+
+// A CSRBuilder over sparse ids, remapping them to dense indices as edges are added.
+type RemappedCSRBuilder[T Integer, W any] struct {
+	*CSRBuilder[uint32, W]
+	Remapper   *Remapper[T]
+	srcKind    NodeKind
+	targetKind NodeKind
+}
+
+func NewRemappedCSRBuilder[T Integer, W any](r *Remapper[T], srcKind, targetKind NodeKind, weighted bool) *RemappedCSRBuilder[T, W] {
+	return &RemappedCSRBuilder[T, W]{
+		CSRBuilder: NewCSRBuilder[uint32, W](weighted),
+		Remapper:   r,
+		srcKind:    srcKind,
+		targetKind: targetKind,
+	}
+}
+
+func (b *RemappedCSRBuilder[T, W]) AddEdge(src, dst T, weight W) {
+	b.CSRBuilder.AddEdge(b.Remapper.Dense(b.srcKind, src), b.Remapper.Dense(b.targetKind, dst), weight)
+}
@@ -0,0 +1,219 @@
+package graph
+
+/*
+Implements the files graphs are written into and read from.
+
+Writers go through a buffered sink, compressed by the extension of the file name:
+.gz for gzip and .zst for zstd. Readers detect compression from the content, so
+a file can be renamed freely.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const sinkBufferSize = 1024 * 1024
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// A buffered, possibly compressed, file being written.
+type Sink struct {
+	*bufio.Writer
+	compressor io.WriteCloser // nil for uncompressed files
+	file       *os.File
+	closed     bool
+}
+
+/*
+Creates a file for writing, buffered, and compressed by the extension of filename.
+Close must be called for the content to be written, and may be called more than once.
+*/
+func CreateSink(filename string) (*Sink, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	s := &Sink{file: file}
+	var target io.Writer = file
+	switch compressionSuffix(filename) {
+	case ".gz":
+		s.compressor = gzip.NewWriter(file)
+		target = s.compressor
+	case ".zst":
+		encoder, err := zstd.NewWriter(file)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		s.compressor = encoder
+		target = encoder
+	}
+	s.Writer = bufio.NewWriterSize(target, sinkBufferSize)
+	return s, nil
+}
+
+// The compression extension of filename (.gz or .zst) that CreateSink compresses by, or "".
+func compressionSuffix(filename string) string {
+	switch ext := filepath.Ext(filename); ext {
+	case ".gz", ".zst":
+		return ext
+	}
+	return ""
+}
+
+// Flushes the buffer and the compressor, and closes the file, returning the first error.
+func (s *Sink) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	err := s.Flush()
+	if s.compressor != nil {
+		if closeErr := s.compressor.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// A reader closing the decompressor along with the file.
+type source struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Whether the content is decompressed, the decompressor being closed along with the file.
+func (s *source) compressed() bool {
+	return len(s.closers) > 1
+}
+
+func (s *source) Close() error {
+	var err error
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		if closeErr := s.closers[i].Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+type zstdCloser struct{ *zstd.Decoder }
+
+func (z zstdCloser) Close() error {
+	z.Decoder.Close()
+	return nil
+}
+
+/*
+Wraps reader with a buffered reader, decompressing gzip and zstd content.
+closing the result does not close reader.
+*/
+func Decompress(reader io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReaderSize(reader, sinkBufferSize)
+	magic, _ := buffered.Peek(len(zstdMagic))
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		return &source{Reader: bufio.NewReaderSize(gz, sinkBufferSize), closers: []io.Closer{gz}}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		decoder, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		return &source{Reader: bufio.NewReaderSize(decoder, sinkBufferSize), closers: []io.Closer{zstdCloser{decoder}}}, nil
+	}
+	return &source{Reader: buffered}, nil
+}
+
+// Opens a file for reading, buffered, and decompressed when its content is gzip or zstd.
+func OpenSource(filename string) (io.ReadCloser, error) {
+	return openSource(filename)
+}
+
+func openSource(filename string) (*source, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	reader, err := Decompress(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	s := reader.(*source)
+	s.closers = append([]io.Closer{file}, s.closers...)
+	return s, nil
+}
+
+/*
+The buffered writer over w, which is w itself when it is buffered already, as the writer
+of a Sink is, so that outputs go through the sink's buffer alone.
+*/
+func BufferedWriter(w io.Writer) *bufio.Writer {
+	if buffered, ok := w.(*bufio.Writer); ok {
+		return buffered
+	}
+	return bufio.NewWriter(w)
+}
+
+// The buffered reader over r, which is r itself when it is buffered already, see BufferedWriter.
+func BufferedReader(r io.Reader) *bufio.Reader {
+	if buffered, ok := r.(*bufio.Reader); ok {
+		return buffered
+	}
+	return bufio.NewReader(r)
+}
+
+/*
+Writes a file through a sink, so write gets a buffered writer compressed by the extension.
+returns the first error of write or of closing the sink, with the file name.
+an id mapping left next to outputFile by a previous remapped output is removed, as readers
+would restore ids by it. the *Remapped writers write theirs after the graph.
+*/
+func writeFile(outputFile string, write func(w io.Writer) error) error {
+	if err := os.Remove(RemapFile(outputFile)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	file, err := CreateSink(outputFile)
+	if err != nil {
+		return err
+	}
+	if err := write(file.Writer); err != nil {
+		file.Close()
+		return fmt.Errorf("%v: %w", outputFile, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("%v: %w", outputFile, err)
+	}
+	return nil
+}
+
+// Reads a file through OpenSource, giving read the buffered reader, returning the first error of read with the file name.
+func readFile(filename string, read func(r io.Reader) error) error {
+	file, err := openSource(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := read(file.Reader); err != nil {
+		return fmt.Errorf("%v: %w", filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package graph
+
+/*
+Implements time windowed snapshots of a graph, and their writers.
+*/
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The length of the windows snapshots are bucketed into. all windows are in UTC.
+type Window int
+
+const (
+	Daily  Window = iota
+	Weekly        // starting on monday
+	Monthly
+)
+
+func ParseWindow(s string) (Window, error) {
+	switch strings.ToLower(s) {
+	case "daily", "day":
+		return Daily, nil
+	case "weekly", "week":
+		return Weekly, nil
+	case "monthly", "month":
+		return Monthly, nil
+	}
+	return Daily, fmt.Errorf("unknown window %q (daily/weekly/monthly)", s)
+}
+
+func (w Window) String() string {
+	switch w {
+	case Weekly:
+		return "weekly"
+	case Monthly:
+		return "monthly"
+	default:
+		return "daily"
+	}
+}
+
+// The start of the window t falls in.
+func (w Window) Start(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch w {
+	case Weekly:
+		sinceMonday := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -sinceMonday)
+	case Monthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// The label of the window starting at start, 2025-01-06 for days and weeks, 2025-01 for months.
+func (w Window) Label(start time.Time) string {
+	if w == Monthly {
+		return start.UTC().Format("2006-01")
+	}
+	return start.UTC().Format("2006-01-02")
+}
+
+// A graph per window, keyed by the unix seconds of the window start.
+type Snapshots[T comparable, U any] struct {
+	Window Window
+	Graphs map[int64]Graph[T, U]
+}
+
+func NewSnapshots[T comparable, U any](window Window) Snapshots[T, U] {
+	return Snapshots[T, U]{Window: window, Graphs: make(map[int64]Graph[T, U])}
+}
+
+// Returns the graph of the window t falls in, creating it if needed.
+func (s Snapshots[T, U]) At(t time.Time) Graph[T, U] {
+	start := s.Window.Start(t).Unix()
+	graph, ok := s.Graphs[start]
+	if !ok {
+		graph = make(Graph[T, U])
+		s.Graphs[start] = graph
+	}
+	return graph
+}
+
+// The window starts, sorted.
+func (s Snapshots[T, U]) Starts() []int64 {
+	starts := make([]int64, 0, len(s.Graphs))
+	for start := range s.Graphs {
+		starts = append(starts, start)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	return starts
+}
+
+func (s Snapshots[T, U]) label(start int64) string {
+	return s.Window.Label(time.Unix(start, 0))
+}
+
+/*
+Outputs all the snapshots into a single edge-list file with a window column,
+window src target weight, windows ordered by time.
+*/
+func SnapshotEdgeListOutputGraph[T comparable, U any](outputFile string, snapshots Snapshots[T, U]) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return WriteSnapshotEdgeList(w, snapshots)
+	})
+}
+
+// Writes snapshots in the format of SnapshotEdgeListOutputGraph.
+func WriteSnapshotEdgeList[T comparable, U any](w io.Writer, snapshots Snapshots[T, U]) error {
+	writer := BufferedWriter(w)
+	for _, start := range snapshots.Starts() {
+		label := snapshots.label(start)
+		for src, neighbors := range snapshots.Graphs[start] {
+			for target, weight := range neighbors {
+				if _, err := fmt.Fprintf(writer, "%v %v %v %v\n", label, src, target, weight); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return writer.Flush()
+}
+
+/*
+Outputs every snapshot into its own file using EdgeListOutputGraph, named by inserting
+the window label before the extension (graph.txt -> graph.2025-01-06.txt), and before the
+compression extension (graph.txt.gz -> graph.2025-01-06.txt.gz).
+returns the names of the written files, stopping at the first file that fails.
+*/
+func SnapshotEdgeListOutputFiles[T comparable, U any](outputFile string, snapshots Snapshots[T, U]) ([]string, error) {
+	compression := compressionSuffix(outputFile)
+	uncompressed := strings.TrimSuffix(outputFile, compression)
+	ext := filepath.Ext(uncompressed) + compression
+	base := strings.TrimSuffix(uncompressed, filepath.Ext(uncompressed))
+
+	files := make([]string, 0, len(snapshots.Graphs))
+	for _, start := range snapshots.Starts() {
+		name := base + "." + snapshots.label(start) + ext
+		if err := EdgeListOutputGraph(name, snapshots.Graphs[start]); err != nil {
+			return files, err
+		}
+		files = append(files, name)
+	}
+	return files, nil
+}
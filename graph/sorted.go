@@ -0,0 +1,516 @@
+package graph
+
+/*
+Implements deterministic output, sorted by node and then by neighbor, so that outputs of
+identical inputs are identical and can be diffed and checksummed.
+
+Graphs in memory are sorted a node at a time. Graphs larger than memory are streamed into an
+EdgeSorter, which sorts runs of edges in memory, spills them into temporary files, and merges
+them back while writing, so the output never needs the whole graph in memory. EdgeSorter is
+for library users streaming their own edges, the actions of main build graphs in memory.
+*/
+
+import (
+	"bufio"
+	"cmp"
+	"container/heap"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// Edges in sorted order, given to yield, stopping at the first error it returns.
+type sortedEdges[T Integer, U any] func(yield func(src, target T, weight U) error) error
+
+// Nodes with their sorted neighbors, in sorted order.
+type sortedNodes[T Integer] func(yield func(src T, neighbors []T) error) error
+
+func sortedKeys[T Integer, V any](m map[T]V, keys []T) []T {
+	keys = keys[:0]
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func graphEdges[T Integer, U any](graph Graph[T, U]) sortedEdges[T, U] {
+	return func(yield func(src, target T, weight U) error) error {
+		var targets []T
+		for _, src := range sortedKeys(graph, nil) {
+			neighbors := graph[src]
+			targets = sortedKeys(neighbors, targets)
+			for _, target := range targets {
+				if err := yield(src, target, neighbors[target]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// The nodes of a graph, including those with no neighbors.
+func graphNodes[T Integer, U any](graph Graph[T, U]) sortedNodes[T] {
+	return func(yield func(src T, neighbors []T) error) error {
+		var targets []T
+		for _, src := range sortedKeys(graph, nil) {
+			targets = sortedKeys(graph[src], targets)
+			if err := yield(src, targets); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// Groups sorted edges by source, buffering a node's neighbors at a time.
+func groupEdges[T Integer, U any](edges sortedEdges[T, U]) sortedNodes[T] {
+	return func(yield func(src T, neighbors []T) error) error {
+		var current T
+		var neighbors []T
+		err := edges(func(src, target T, weight U) error {
+			if len(neighbors) > 0 && src != current {
+				if err := yield(current, neighbors); err != nil {
+					return err
+				}
+				neighbors = neighbors[:0]
+			}
+			current = src
+			neighbors = append(neighbors, target)
+			return nil
+		})
+		if err != nil || len(neighbors) == 0 {
+			return err
+		}
+		return yield(current, neighbors)
+	}
+}
+
+// Passes a buffered writer over out to write.
+func writeSorted(out io.Writer, write func(w *bufio.Writer) error) error {
+	w := BufferedWriter(out)
+	if err := write(w); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// Writes edges in the format of EdgeListOutputGraph.
+func writeSortedEdgeList[T Integer, U any](out io.Writer, edges sortedEdges[T, U]) error {
+	return writeSorted(out, func(w *bufio.Writer) error {
+		return edges(func(src, target T, weight U) error {
+			_, err := fmt.Fprintf(w, "%v %v %v\n", src, target, weight)
+			return err
+		})
+	})
+}
+
+// Writes nodes in the format of NeighborOutputGraph.
+func writeSortedNeighbors[T Integer](out io.Writer, nodes sortedNodes[T]) error {
+	return writeSorted(out, func(w *bufio.Writer) error {
+		return nodes(func(src T, neighbors []T) error {
+			fmt.Fprintf(w, "%v", src)
+			for _, neighbor := range neighbors {
+				fmt.Fprintf(w, " %v", neighbor)
+			}
+			return w.WriteByte('\n')
+		})
+	})
+}
+
+// Writes edges in the format of LabeledEdgeListOutputGraph.
+func writeSortedLabeledEdgeList[T Integer, U any](out io.Writer, edges sortedEdges[T, U], srcLabel, targetLabel Labeler[T]) error {
+	return writeSorted(out, func(w *bufio.Writer) error {
+		return edges(func(src, target T, weight U) error {
+			_, err := fmt.Fprintf(w, "%v %v %v\n", srcLabel(src), targetLabel(target), weight)
+			return err
+		})
+	})
+}
+
+// Writes nodes in the format of LabeledNeighborOutputGraph.
+func writeSortedLabeledNeighbors[T Integer](out io.Writer, nodes sortedNodes[T], srcLabel, targetLabel Labeler[T]) error {
+	return writeSorted(out, func(w *bufio.Writer) error {
+		return nodes(func(src T, neighbors []T) error {
+			w.WriteString(srcLabel(src))
+			for _, neighbor := range neighbors {
+				w.WriteByte(' ')
+				w.WriteString(targetLabel(neighbor))
+			}
+			return w.WriteByte('\n')
+		})
+	})
+}
+
+// Writes nodes in the format of WriteNeighborGraphBinary.
+func writeSortedNeighborBinary[T Integer](out io.Writer, nodes sortedNodes[T]) error {
+	return writeSorted(out, func(w *bufio.Writer) error {
+		return nodes(func(src T, neighbors []T) error {
+			if err := binary.Write(w, binary.LittleEndian, src); err != nil {
+				return err
+			}
+			if err := binary.Write(w, binary.LittleEndian, T(len(neighbors))); err != nil {
+				return err
+			}
+			return binary.Write(w, binary.LittleEndian, neighbors)
+		})
+	})
+}
+
+// Same as EdgeListOutputGraph, but sorted by source and then by target.
+func SortedEdgeListOutputGraph[T Integer, U any](outputFile string, graph Graph[T, U]) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return WriteSortedEdgeList(w, graph)
+	})
+}
+
+// Writes a graph in the format of SortedEdgeListOutputGraph.
+func WriteSortedEdgeList[T Integer, U any](w io.Writer, graph Graph[T, U]) error {
+	return writeSortedEdgeList(w, graphEdges(graph))
+}
+
+// Same as NeighborOutputGraph, but sorted by node and then by neighbor.
+func SortedNeighborOutputGraph[T Integer, U any](outputFile string, graph Graph[T, U]) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return WriteSortedNeighbors(w, graph)
+	})
+}
+
+// Writes a graph in the format of SortedNeighborOutputGraph.
+func WriteSortedNeighbors[T Integer, U any](w io.Writer, graph Graph[T, U]) error {
+	return writeSortedNeighbors(w, graphNodes(graph))
+}
+
+// Same as LabeledEdgeListOutputGraph, but sorted by source and then by target id (not by label).
+func SortedLabeledEdgeListOutputGraph[T Integer, U any](outputFile string, graph Graph[T, U], srcLabel, targetLabel Labeler[T]) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return WriteSortedLabeledEdgeList(w, graph, srcLabel, targetLabel)
+	})
+}
+
+// Writes a graph in the format of SortedLabeledEdgeListOutputGraph.
+func WriteSortedLabeledEdgeList[T Integer, U any](w io.Writer, graph Graph[T, U], srcLabel, targetLabel Labeler[T]) error {
+	return writeSortedLabeledEdgeList(w, graphEdges(graph), srcLabel, targetLabel)
+}
+
+// Same as LabeledNeighborOutputGraph, but sorted by node and then by neighbor id (not by label).
+func SortedLabeledNeighborOutputGraph[T Integer, U any](outputFile string, graph Graph[T, U], srcLabel, targetLabel Labeler[T]) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return WriteSortedLabeledNeighbors(w, graph, srcLabel, targetLabel)
+	})
+}
+
+// Writes a graph in the format of SortedLabeledNeighborOutputGraph.
+func WriteSortedLabeledNeighbors[T Integer, U any](w io.Writer, graph Graph[T, U], srcLabel, targetLabel Labeler[T]) error {
+	return writeSortedLabeledNeighbors(w, graphNodes(graph), srcLabel, targetLabel)
+}
+
+// Same as WriteNeighborGraphBinary, but sorted by node and then by neighbor.
+func WriteNeighborGraphBinarySorted[T Integer](filename string, graph map[T]map[T]struct{}) error {
+	return writeFile(filename, func(w io.Writer) error {
+		return WriteNeighborsBinarySorted(w, graph)
+	})
+}
+
+// Writes a graph in the format of WriteNeighborGraphBinarySorted.
+func WriteNeighborsBinarySorted[T Integer](w io.Writer, graph map[T]map[T]struct{}) error {
+	return writeSortedNeighborBinary(w, graphNodes(Graph[T, struct{}](graph)))
+}
+
+/*
+Sorts edges that may not fit in memory. edges are kept in memory up to a limit, and then
+sorted and spilled as a run into a temporary file. the weight U must have a fixed size
+(see encoding/binary), as runs are written in binary.
+
+Duplicate edges are collapsed into one, with the weight of the last added, as in a Graph.
+runs are closed once spilled, and
+merged at most maxMergeRuns at a time, merging in several passes when there are more, so
+the open files stay bounded whatever the number of runs.
+*/
+type EdgeSorter[T Integer, U any] struct {
+	limit int
+	dir   string
+	edges []csrEdge[T, U]
+	runs  []string // the names of the spilled runs
+	fanIn int      // the most runs merged at once
+}
+
+// The most runs an EdgeSorter keeps open while merging.
+const maxMergeRuns = 64
+
+/*
+Creates a sorter keeping up to limit edges in memory, spilling runs into dir
+(the default temporary directory when empty). Close removes the runs.
+*/
+func NewEdgeSorter[T Integer, U any](limit int, dir string) (*EdgeSorter[T, U], error) {
+	var zero U
+	if binary.Size(zero) < 0 {
+		return nil, fmt.Errorf("edge sorter weights must have a fixed size, got %T", zero)
+	}
+	if limit <= 0 {
+		return nil, fmt.Errorf("edge sorter limit must be positive, got %d", limit)
+	}
+	return &EdgeSorter[T, U]{limit: limit, dir: dir, fanIn: maxMergeRuns}, nil
+}
+
+func (s *EdgeSorter[T, U]) Add(src, target T, weight U) error {
+	s.edges = append(s.edges, csrEdge[T, U]{src, target, weight})
+	if len(s.edges) >= s.limit {
+		return s.spill()
+	}
+	return nil
+}
+
+// Adds all the edges of a graph.
+func (s *EdgeSorter[T, U]) AddGraph(graph Graph[T, U]) error {
+	for src, neighbors := range graph {
+		for target, weight := range neighbors {
+			if err := s.Add(src, target, weight); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func compareEdges[T Integer, U any](x, y csrEdge[T, U]) int {
+	if c := cmp.Compare(x.src, y.src); c != 0 {
+		return c
+	}
+	return cmp.Compare(x.dst, y.dst)
+}
+
+// Sorts edges stably, collapsing duplicates into the last added.
+func sortEdges[T Integer, U any](edges []csrEdge[T, U]) []csrEdge[T, U] {
+	slices.SortStableFunc(edges, compareEdges)
+	kept := edges[:0]
+	for _, edge := range edges {
+		if len(kept) > 0 && compareEdges(kept[len(kept)-1], edge) == 0 {
+			kept[len(kept)-1] = edge
+			continue
+		}
+		kept = append(kept, edge)
+	}
+	return kept
+}
+
+// Sorts the edges in memory and writes them into a new run.
+func (s *EdgeSorter[T, U]) spill() error {
+	edges := sortEdges(s.edges)
+	run, err := s.writeRun(func(write func(src, target T, weight U) error) error {
+		for _, edge := range edges {
+			if err := write(edge.src, edge.dst, edge.weight); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, run)
+	s.edges = s.edges[:0]
+	return nil
+}
+
+// Writes the edges given by edges into a new run, closed once written, returning its name. the run is removed on errors.
+func (s *EdgeSorter[T, U]) writeRun(edges sortedEdges[T, U]) (string, error) {
+	file, err := os.CreateTemp(s.dir, "edges-*.run")
+	if err != nil {
+		return "", err
+	}
+	writer := bufio.NewWriter(file)
+	err = edges(func(src, target T, weight U) error {
+		return writeRunEdge(writer, csrEdge[T, U]{src, target, weight})
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+func writeRunEdge[T Integer, U any](w io.Writer, edge csrEdge[T, U]) error {
+	if err := binary.Write(w, binary.LittleEndian, edge.src); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, edge.dst); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, edge.weight)
+}
+
+func readRunEdge[T Integer, U any](r io.Reader, edge *csrEdge[T, U]) error {
+	if err := binary.Read(r, binary.LittleEndian, &edge.src); err != nil {
+		return err
+	}
+	if err := binary.Read(r, binary.LittleEndian, &edge.dst); err != nil {
+		return err
+	}
+	return binary.Read(r, binary.LittleEndian, &edge.weight)
+}
+
+// A run being merged, holding its next edge.
+type runCursor[T Integer, U any] struct {
+	reader *bufio.Reader
+	edge   csrEdge[T, U]
+	order  int // the position of the run, later runs hold later added edges
+}
+
+type runHeap[T Integer, U any] []*runCursor[T, U]
+
+func (h runHeap[T, U]) Len() int { return len(h) }
+func (h runHeap[T, U]) Less(i, j int) bool {
+	if c := compareEdges(h[i].edge, h[j].edge); c != 0 {
+		return c < 0
+	}
+	return h[i].order < h[j].order
+}
+func (h runHeap[T, U]) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *runHeap[T, U]) Push(x any)   { *h = append(*h, x.(*runCursor[T, U])) }
+func (h *runHeap[T, U]) Pop() any {
+	old := *h
+	cursor := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return cursor
+}
+
+/*
+Gives all the added edges to fn in sorted order, merging the runs. the sorter
+should not be added to afterwards.
+*/
+func (s *EdgeSorter[T, U]) Each(fn func(src, target T, weight U) error) error {
+	if len(s.runs) == 0 {
+		s.edges = sortEdges(s.edges)
+		for _, edge := range s.edges {
+			if err := fn(edge.src, edge.dst, edge.weight); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(s.edges) > 0 {
+		if err := s.spill(); err != nil {
+			return err
+		}
+	}
+
+	// merge passes, each replacing the first fanIn runs with one, so runs stay in the order they were added
+	for len(s.runs) > s.fanIn {
+		merged := s.runs[:s.fanIn]
+		run, err := s.writeRun(func(write func(src, target T, weight U) error) error {
+			return mergeRuns(merged, write)
+		})
+		if err != nil {
+			return err
+		}
+		for _, run := range merged {
+			os.Remove(run)
+		}
+		s.runs = append([]string{run}, s.runs[s.fanIn:]...)
+	}
+	return mergeRuns(s.runs, fn)
+}
+
+/*
+Gives the edges of sorted runs to fn in sorted order, with a file open per run. duplicate
+edges are collapsed into the one of the last run, runs being in the order they were added.
+*/
+func mergeRuns[T Integer, U any](runs []string, fn func(src, target T, weight U) error) error {
+	h := make(runHeap[T, U], 0, len(runs))
+	for i, run := range runs {
+		file, err := os.Open(run)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		cursor := &runCursor[T, U]{reader: bufio.NewReader(file), order: i}
+		if err := readRunEdge(cursor.reader, &cursor.edge); err != nil {
+			if err == io.EOF {
+				continue
+			}
+			return err
+		}
+		h = append(h, cursor)
+	}
+	heap.Init(&h)
+	var pending csrEdge[T, U]
+	hasPending := false
+	for h.Len() > 0 {
+		cursor := h[0]
+		if hasPending && compareEdges(pending, cursor.edge) != 0 {
+			if err := fn(pending.src, pending.dst, pending.weight); err != nil {
+				return err
+			}
+		}
+		pending, hasPending = cursor.edge, true
+		if err := readRunEdge(cursor.reader, &cursor.edge); err != nil {
+			if err != io.EOF {
+				return err
+			}
+			heap.Pop(&h)
+			continue
+		}
+		heap.Fix(&h, 0)
+	}
+	if !hasPending {
+		return nil
+	}
+	return fn(pending.src, pending.dst, pending.weight)
+}
+
+// The number of runs spilled so far, less those merged into others.
+func (s *EdgeSorter[T, U]) Runs() int {
+	return len(s.runs)
+}
+
+// Removes the runs.
+func (s *EdgeSorter[T, U]) Close() error {
+	var first error
+	for _, run := range s.runs {
+		if err := os.Remove(run); err != nil && first == nil {
+			first = err
+		}
+	}
+	s.runs, s.edges = nil, nil
+	return first
+}
+
+// Writes the sorted edges in the format of EdgeListOutputGraph.
+func (s *EdgeSorter[T, U]) EdgeListOutput(outputFile string) error {
+	return writeFile(outputFile, s.WriteEdgeList)
+}
+
+// Writes the sorted edges to w in the format of EdgeListOutputGraph.
+func (s *EdgeSorter[T, U]) WriteEdgeList(w io.Writer) error {
+	return writeSortedEdgeList(w, s.Each)
+}
+
+// Writes the sorted edges in the format of NeighborOutputGraph.
+func (s *EdgeSorter[T, U]) NeighborOutput(outputFile string) error {
+	return writeFile(outputFile, s.WriteNeighbors)
+}
+
+// Writes the sorted edges to w in the format of NeighborOutputGraph.
+func (s *EdgeSorter[T, U]) WriteNeighbors(w io.Writer) error {
+	return writeSortedNeighbors(w, groupEdges(s.Each))
+}
+
+// Writes the sorted edges in the format of WriteNeighborGraphBinary.
+func (s *EdgeSorter[T, U]) NeighborBinaryOutput(filename string) error {
+	return writeFile(filename, s.WriteNeighborsBinary)
+}
+
+// Writes the sorted edges to w in the format of WriteNeighborGraphBinary.
+func (s *EdgeSorter[T, U]) WriteNeighborsBinary(w io.Writer) error {
+	return writeSortedNeighborBinary(w, groupEdges(s.Each))
+}
@@ -0,0 +1,136 @@
+package graph
+
+/*
+Implements the temporal edge payload, and its edge-list writer and reader.
+*/
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+An edge holding when the interaction started and stopped.
+First and Last are unix seconds, ActiveDays the number of distinct (UTC) days with an interaction.
+*/
+type TemporalEdge struct {
+	First      int64
+	Last       int64
+	Count      uint32
+	ActiveDays uint32
+}
+
+// Formats the edge as the columns first last count activeDays, with times as RFC3339.
+func (e TemporalEdge) String() string {
+	return fmt.Sprintf("%s %s %d %d",
+		time.Unix(e.First, 0).UTC().Format(time.RFC3339),
+		time.Unix(e.Last, 0).UTC().Format(time.RFC3339),
+		e.Count, e.ActiveDays)
+}
+
+/*
+Outputs a temporal graph in the edge-list format src target first last count activeDays.
+*/
+func TemporalEdgeListOutputGraph[T comparable](outputFile string, graph Graph[T, TemporalEdge]) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return WriteTemporalEdgeList(w, graph)
+	})
+}
+
+// Writes a temporal graph in the format of TemporalEdgeListOutputGraph.
+func WriteTemporalEdgeList[T comparable](w io.Writer, graph Graph[T, TemporalEdge]) error {
+	writer := BufferedWriter(w)
+	for src, neighbors := range graph {
+		for target, edge := range neighbors {
+			if _, err := fmt.Fprintf(writer, "%v %v %v\n", src, target, edge); err != nil {
+				return err
+			}
+		}
+	}
+	return writer.Flush()
+}
+
+/*
+Reads a graph written by TemporalEdgeListOutputGraph. times may be either RFC3339 or unix seconds.
+*/
+func ReadTemporalEdgeList[T Integer](filename string) (Graph[T, TemporalEdge], error) {
+	var graph Graph[T, TemporalEdge]
+	err := readFile(filename, func(r io.Reader) (err error) {
+		graph, err = ReadTemporalEdges[T](r)
+		return err
+	})
+	return graph, err
+}
+
+// Reads a temporal graph in the format of TemporalEdgeListOutputGraph.
+func ReadTemporalEdges[T Integer](r io.Reader) (Graph[T, TemporalEdge], error) {
+	graph := make(Graph[T, TemporalEdge])
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		tokens := strings.Fields(line)
+		if len(tokens) != 6 {
+			return nil, fmt.Errorf("line %d: expected 6 columns, got %d", lineNumber, len(tokens))
+		}
+		src, err := parseInteger[T](tokens[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		target, err := parseInteger[T](tokens[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+		edge, err := parseTemporalEdge(tokens[2:])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+
+		if graph[src] == nil {
+			graph[src] = make(map[T]TemporalEdge)
+		}
+		graph[src][target] = edge
+	}
+	return graph, scanner.Err()
+}
+
+func parseTemporalEdge(tokens []string) (TemporalEdge, error) {
+	var edge TemporalEdge
+	var err error
+	if edge.First, err = parseTime(tokens[0]); err != nil {
+		return edge, err
+	}
+	if edge.Last, err = parseTime(tokens[1]); err != nil {
+		return edge, err
+	}
+	count, err := strconv.ParseUint(tokens[2], 10, 32)
+	if err != nil {
+		return edge, err
+	}
+	days, err := strconv.ParseUint(tokens[3], 10, 32)
+	if err != nil {
+		return edge, err
+	}
+	edge.Count, edge.ActiveDays = uint32(count), uint32(days)
+	return edge, nil
+}
+
+// Parses RFC3339 or unix seconds into unix seconds.
+func parseTime(s string) (int64, error) {
+	if seconds, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return seconds, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
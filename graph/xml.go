@@ -0,0 +1,203 @@
+package graph
+
+/*
+Implements GraphML and GEXF outputs, for visualizing graphs in Gephi, yEd and the likes.
+
+Edges are written as they go through the graph. Nodes are written first, so xmlNodes
+collects them with their degrees and labels in memory, but not the edges. Nodes are identified by their kind and id (user:42), so users and repos sharing an id stay
+apart, and carry their kind, label and degree (in + out) as attributes.
+Numeric weights are written as edge weights, other weights (except struct{}) as edge labels.
+*/
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+)
+
+// The nodes of a graph with their degrees and labels, sorted by kind and id.
+type xmlNode struct {
+	Node
+	degree int
+	label  string
+}
+
+func xmlNodes[T Integer, U any](graph Graph[T, U], srcKind, targetKind NodeKind, srcLabel, targetLabel Labeler[T]) []xmlNode {
+	degrees := make(map[Node]int)
+	labels := make(map[Node]string)
+	add := func(kind NodeKind, id T, labeler Labeler[T], degree int) {
+		node := Node{Kind: kind, ID: uint64(id)}
+		if _, ok := degrees[node]; !ok && labeler != nil {
+			labels[node] = labeler(id)
+		}
+		degrees[node] += degree
+	}
+	for src, neighbors := range graph {
+		add(srcKind, src, srcLabel, len(neighbors))
+		for target := range neighbors {
+			add(targetKind, target, targetLabel, 1)
+		}
+	}
+
+	nodes := make([]xmlNode, 0, len(degrees))
+	for node, degree := range degrees {
+		label, ok := labels[node]
+		if !ok {
+			label = strconv.FormatUint(node.ID, 10)
+		}
+		nodes = append(nodes, xmlNode{Node: node, degree: degree, label: label})
+	}
+	slices.SortFunc(nodes, func(a, b xmlNode) int {
+		if a.Kind != b.Kind {
+			return cmp.Compare(a.Kind, b.Kind)
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+	return nodes
+}
+
+// How the weights of U are written, as a number, as a label, or not at all.
+func xmlWeightKind[U any]() (numeric bool, labeled bool) {
+	if kind, _ := weightKindOf[U](); kind != weightNone {
+		return true, false
+	}
+	_, unweighted := any(*new(U)).(struct{})
+	return false, !unweighted
+}
+
+type xmlWriter struct {
+	*bufio.Writer
+	err error
+}
+
+// Writes formatted text, keeping the first error.
+func (w *xmlWriter) printf(format string, args ...any) {
+	if w.err == nil {
+		_, w.err = fmt.Fprintf(w.Writer, format, args...)
+	}
+}
+
+// Writes escaped text, keeping the first error.
+func (w *xmlWriter) text(s string) {
+	if w.err == nil {
+		w.err = xml.EscapeText(w.Writer, []byte(s))
+	}
+}
+
+// Writes the xml declaration and the document given by write into out.
+func writeXML(out io.Writer, write func(w *xmlWriter)) error {
+	w := &xmlWriter{Writer: BufferedWriter(out)}
+	w.printf("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	write(w)
+	if w.err != nil {
+		return w.err
+	}
+	return w.Flush()
+}
+
+/*
+Outputs a graph in the GraphML format, with sources of srcKind and targets of targetKind.
+the labelers may be nil, labeling nodes by their id.
+*/
+func WriteGraphML[T Integer, U any](outputFile string, graph Graph[T, U], srcKind, targetKind NodeKind, srcLabel, targetLabel Labeler[T]) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return WriteGraphMLTo(w, graph, srcKind, targetKind, srcLabel, targetLabel)
+	})
+}
+
+// Writes a graph in the GraphML format, see WriteGraphML.
+func WriteGraphMLTo[T Integer, U any](out io.Writer, graph Graph[T, U], srcKind, targetKind NodeKind, srcLabel, targetLabel Labeler[T]) error {
+	numeric, labeled := xmlWeightKind[U]()
+	return writeXML(out, func(w *xmlWriter) {
+		w.printf("<graphml xmlns=\"http://graphml.graphdrawing.org/xmlns\">\n")
+		w.printf("  <key id=\"kind\" for=\"node\" attr.name=\"kind\" attr.type=\"string\"/>\n")
+		w.printf("  <key id=\"label\" for=\"node\" attr.name=\"label\" attr.type=\"string\"/>\n")
+		w.printf("  <key id=\"degree\" for=\"node\" attr.name=\"degree\" attr.type=\"int\"/>\n")
+		if numeric {
+			w.printf("  <key id=\"weight\" for=\"edge\" attr.name=\"weight\" attr.type=\"double\"/>\n")
+		} else if labeled {
+			w.printf("  <key id=\"edgelabel\" for=\"edge\" attr.name=\"label\" attr.type=\"string\"/>\n")
+		}
+		w.printf("  <graph id=\"G\" edgedefault=\"directed\">\n")
+
+		for _, node := range xmlNodes(graph, srcKind, targetKind, srcLabel, targetLabel) {
+			w.printf("    <node id=\"%v\"><data key=\"kind\">%v</data><data key=\"label\">", node.Node, node.Kind)
+			w.text(node.label)
+			w.printf("</data><data key=\"degree\">%d</data></node>\n", node.degree)
+		}
+
+		for src, neighbors := range graph {
+			source := Node{Kind: srcKind, ID: uint64(src)}
+			for target, weight := range neighbors {
+				w.printf("    <edge source=\"%v\" target=\"%v\"", source, Node{Kind: targetKind, ID: uint64(target)})
+				switch {
+				case numeric:
+					w.printf("><data key=\"weight\">%v</data></edge>\n", weight)
+				case labeled:
+					w.printf("><data key=\"edgelabel\">")
+					w.text(fmt.Sprint(weight))
+					w.printf("</data></edge>\n")
+				default:
+					w.printf("/>\n")
+				}
+			}
+		}
+		w.printf("  </graph>\n</graphml>\n")
+	})
+}
+
+/*
+Outputs a graph in the GEXF 1.3 format (the native format of Gephi), with sources of srcKind
+and targets of targetKind. the labelers may be nil, labeling nodes by their id.
+*/
+func WriteGEXF[T Integer, U any](outputFile string, graph Graph[T, U], srcKind, targetKind NodeKind, srcLabel, targetLabel Labeler[T]) error {
+	return writeFile(outputFile, func(w io.Writer) error {
+		return WriteGEXFTo(w, graph, srcKind, targetKind, srcLabel, targetLabel)
+	})
+}
+
+// Writes a graph in the GEXF format, see WriteGEXF.
+func WriteGEXFTo[T Integer, U any](out io.Writer, graph Graph[T, U], srcKind, targetKind NodeKind, srcLabel, targetLabel Labeler[T]) error {
+	numeric, labeled := xmlWeightKind[U]()
+	return writeXML(out, func(w *xmlWriter) {
+		w.printf("<gexf xmlns=\"http://gexf.net/1.3\" version=\"1.3\">\n")
+		w.printf("  <graph mode=\"static\" defaultedgetype=\"directed\">\n")
+		w.printf("    <attributes class=\"node\">\n")
+		w.printf("      <attribute id=\"kind\" title=\"kind\" type=\"string\"/>\n")
+		w.printf("      <attribute id=\"degree\" title=\"degree\" type=\"integer\"/>\n")
+		w.printf("    </attributes>\n")
+
+		w.printf("    <nodes>\n")
+		for _, node := range xmlNodes(graph, srcKind, targetKind, srcLabel, targetLabel) {
+			w.printf("      <node id=\"%v\" label=\"", node.Node)
+			w.text(node.label)
+			w.printf("\"><attvalues><attvalue for=\"kind\" value=\"%v\"/><attvalue for=\"degree\" value=\"%d\"/></attvalues></node>\n", node.Kind, node.degree)
+		}
+		w.printf("    </nodes>\n")
+
+		w.printf("    <edges>\n")
+		id := 0
+		for src, neighbors := range graph {
+			source := Node{Kind: srcKind, ID: uint64(src)}
+			for target, weight := range neighbors {
+				w.printf("      <edge id=\"%d\" source=\"%v\" target=\"%v\"", id, source, Node{Kind: targetKind, ID: uint64(target)})
+				switch {
+				case numeric:
+					w.printf(" weight=\"%v\"", weight)
+				case labeled:
+					w.printf(" label=\"")
+					w.text(fmt.Sprint(weight))
+					w.printf("\"")
+				}
+				w.printf("/>\n")
+				id++
+			}
+		}
+		w.printf("    </edges>\n")
+		w.printf("  </graph>\n</gexf>\n")
+	})
+}
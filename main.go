@@ -6,34 +6,297 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"path/filepath"
 	"runtime"
 	"stream-parser/graph"
 	"stream-parser/myjson"
+	"stream-parser/myjson/bots"
 	"log"
 	collabgraph "stream-parser/myjson/collab_graph"
+	"stream-parser/myjson/dictionary"
+	"stream-parser/myjson/infer"
 )
 
 
-func collabGraph(files []string, inputType string) graph.Graph[uint32, struct{}]{
-	manager := collabgraph.CollabGraphManeger
-	collabGraph, err := myjson.ParseInParallel(files, manager, inputType)
+// The exit code of the run, set by fail.
+var exitCode = 0
+
+// Reports an error to stderr, making the run exit non-zero once done.
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format, args...)
+	exitCode = 1
+}
+
+// Reports an error writing outputFile, if any.
+func checkOutput(outputFile string, err error) {
+	if (err != nil) {
+		fail("Error writing %v: %v\n", outputFile, err)
+	}
+}
+
+/*
+Parses the files with myjson.ParseInParallelWithOptions, failing the run when any of the
+sources could not be read, while still returning what was parsed from the others.
+*/
+func parse[T any, R any](files []string, manager myjson.ManagerFunc[T, R], inputType string, options myjson.ParseOptions) (R, error) {
+	result, summary, err := myjson.ParseInParallelWithOptions(files, manager, inputType, options)
+	if (err == nil && summary.Failed > 0) {
+		fail("Failed to read %d of %d sources\n", summary.Failed, len(files))
+	}
+	return result, err
+}
+
+// The actions that key repos by canonical identity under -canonical.
+var canonicalActions = map[string]bool{
+	"collabGraph":               true,
+	"collabGraphBinary":         true,
+	"weightedCollabGraph":       true,
+	"weightedCollabGraphBinary": true,
+}
+
+// Builds the resolver of -canonical from the dictionary, applying the links file if given.
+func canonicalResolver(dict *dictionary.Dictionary, linksFile string) (*dictionary.Resolver, error) {
+	resolver := dictionary.NewResolver(dict.Repos)
+	if (linksFile != "") {
+		if err := resolver.ReadLinks(linksFile); err != nil {
+			return nil, err
+		}
+	}
+	return resolver, nil
+}
+
+func collabGraph(files []string, inputType string, options myjson.ParseOptions, resolver *dictionary.Resolver) graph.Graph[uint32, struct{}]{
+	var collabGraph graph.Graph[uint32, struct{}]
+	var err error
+	if (resolver != nil) {
+		manager := collabgraph.CanonicalCollabGraphManeger(resolver)
+		collabGraph, err = parse(files, manager, inputType, options)
+	} else {
+		manager := collabgraph.CollabGraphManeger
+		collabGraph, err = parse(files, manager, inputType, options)
+	}
+	if (err != nil) {
+		fail("Error encounted collabGraph: %s\n", err)
+		return nil
+	}
+	return collabGraph
+}
+
+func weightedCollabGraph(files []string, inputType string, options myjson.ParseOptions, resolver *dictionary.Resolver) graph.Graph[uint32, uint32]{
+	var collabGraph graph.Graph[uint32, uint32]
+	var err error
+	if (resolver != nil) {
+		manager := collabgraph.CanonicalWeightedCollabGraphManeger(resolver)
+		collabGraph, err = parse(files, manager, inputType, options)
+	} else {
+		manager := collabgraph.WeightedCollabGraphManeger
+		collabGraph, err = parse(files, manager, inputType, options)
+	}
 	if (err != nil) {
-		fmt.Fprintf(os.Stderr, "Error encounted collabGraph: %s\n", err)
+		fail("Error encounted collabGraph: %s\n", err)
 		return nil
 	}
 	return collabGraph
 }
 
-func weightedCollabGraph(files []string, inputType string) graph.Graph[uint32, uint32]{
-	manager := collabgraph.WeightedCollabGraphManeger
-	collabGraph, err := myjson.ParseInParallel(files, manager, inputType)
+func typedCollabGraph(files []string, inputType string, options myjson.ParseOptions) graph.Graph[uint32, collabgraph.EventCounts]{
+	manager := collabgraph.TypedCollabGraphManeger
+	collabGraph, err := parse(files, manager, inputType, options)
 	if (err != nil) {
-		fmt.Fprintf(os.Stderr, "Error encounted collabGraph: %s\n", err)
+		fail("Error encounted typedCollabGraph: %s\n", err)
 		return nil
 	}
 	return collabGraph
 }
 
+func tableWeightedCollabGraph(files []string, inputType string, options myjson.ParseOptions, table collabgraph.WeightTable) graph.Graph[uint32, uint32]{
+	manager := collabgraph.TableWeightedCollabGraphManeger(table)
+	collabGraph, err := parse(files, manager, inputType, options)
+	if (err != nil) {
+		fail("Error encounted tableWeightedCollabGraph: %s\n", err)
+		return nil
+	}
+	return collabGraph
+}
+
+func temporalCollabGraph(files []string, inputType string, options myjson.ParseOptions) graph.Graph[uint32, graph.TemporalEdge]{
+	manager := collabgraph.TemporalCollabGraphManeger
+	collabGraph, err := parse(files, manager, inputType, options)
+	if (err != nil) {
+		fail("Error encounted temporalCollabGraph: %s\n", err)
+		return nil
+	}
+	return collabGraph
+}
+
+func windowedCollabGraph(files []string, inputType string, options myjson.ParseOptions, window graph.Window) graph.Snapshots[uint32, uint32]{
+	manager := collabgraph.WindowedCollabGraphManeger(window)
+	snapshots, err := parse(files, manager, inputType, options)
+	if (err != nil) {
+		fail("Error encounted windowedCollabGraph: %s\n", err)
+		return graph.NewSnapshots[uint32, uint32](window)
+	}
+	return snapshots
+}
+
+func forkForest(files []string, inputType string, options myjson.ParseOptions) collabgraph.ForkForest{
+	manager := collabgraph.ForkGraphManeger
+	forest, err := parse(files, manager, inputType, options)
+	if (err != nil) {
+		fail("Error encounted forkForest: %s\n", err)
+	}
+	return forest
+}
+
+func pullRequestGraphs(files []string, inputType string, options myjson.ParseOptions, scope collabgraph.Scope) collabgraph.PullRequestGraphs{
+	manager := collabgraph.PullRequestGraphManeger(scope)
+	graphs, err := parse(files, manager, inputType, options)
+	if (err != nil) {
+		fail("Error encounted pullRequestGraphs: %s\n", err)
+	}
+	return graphs
+}
+
+func issueGraphs(files []string, inputType string, options myjson.ParseOptions) collabgraph.IssueGraphs{
+	manager := collabgraph.IssueGraphManeger
+	graphs, err := parse(files, manager, inputType, options)
+	if (err != nil) {
+		fail("Error encounted issueGraphs: %s\n", err)
+	}
+	return graphs
+}
+
+func orgGraphs(files []string, inputType string, options myjson.ParseOptions) collabgraph.OrgGraphs{
+	manager := collabgraph.OrgGraphManeger
+	graphs, err := parse(files, manager, inputType, options)
+	if (err != nil) {
+		fail("Error encounted orgGraphs: %s\n", err)
+	}
+	return graphs
+}
+
+/*
+Builds the commit author graphs, exiting when no salt is given, as unsalted hashes of
+emails can be reversed by hashing known emails.
+*/
+func commitAuthorGraphs(files []string, inputType string, options myjson.ParseOptions, salt string) collabgraph.CommitAuthorGraphs{
+	if (salt == "") {
+		fail("commit author graphs require a secret -salt, for example -salt $(openssl rand -hex 16)\n")
+		os.Exit(1)
+	}
+	manager := collabgraph.CommitAuthorGraphManeger(salt)
+	graphs, err := parse(files, manager, inputType, options)
+	if (err != nil) {
+		fail("Error encounted commitAuthorGraphs: %s\n", err)
+	}
+	log.Printf("Commits: %d | pushed on behalf of others: %d | unlinked authors: %d\n", graphs.Commits, graphs.OnBehalfCommits, graphs.UnlinkedCommits)
+	return graphs
+}
+
+func inferSchemas(files []string, inputType string, options myjson.ParseOptions) infer.Schemas{
+	manager := infer.SchemaManeger
+	schemas, err := parse(files, manager, inputType, options)
+	if (err != nil) {
+		fail("Error encounted inferSchemas: %s\n", err)
+		return nil
+	}
+	return schemas
+}
+
+// Compares two files written by the inferSchema action, printing the changes.
+func schemaDiff(files []string, threshold float64) {
+	if len(files) != 2 {
+		fail("schemaDiff expects exactly 2 schema files, got %d\n", len(files))
+		return
+	}
+	before, err := infer.ReadSchemas(files[0])
+	if err != nil {
+		fail("Error encounted schemaDiff: %s\n", err)
+		return
+	}
+	after, err := infer.ReadSchemas(files[1])
+	if err != nil {
+		fail("Error encounted schemaDiff: %s\n", err)
+		return
+	}
+	for _, change := range infer.DiffSchemas(before, after, threshold) {
+		fmt.Println(change)
+	}
+}
+
+func buildDictionary(files []string, inputType string, options myjson.ParseOptions) *dictionary.Dictionary{
+	manager := dictionary.DictionaryManeger
+	dict, err := parse(files, manager, inputType, options)
+	if (err != nil) {
+		fail("Error encounted buildDictionary: %s\n", err)
+		return nil
+	}
+	return dict
+}
+
+func classifyBots(files []string, inputType string, options myjson.ParseOptions, config bots.Config) bots.Labels{
+	manager := bots.ActivityManeger
+	activity, err := parse(files, manager, inputType, options)
+	if (err != nil) {
+		fail("Error encounted classifyBots: %s\n", err)
+		return nil
+	}
+	return bots.Classify(activity, config)
+}
+
+// Reads the bot lists and labels given by the flags, exiting on failure.
+func botConfig(allowFile, denyFile string) bots.Config {
+	config := bots.DefaultConfig()
+	var err error
+	if (allowFile != "") {
+		if config.Allow, err = bots.ReadList(allowFile); err != nil {
+			fail("Error reading bot allow list: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if (denyFile != "") {
+		if config.Deny, err = bots.ReadList(denyFile); err != nil {
+			fail("Error reading bot deny list: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	return config
+}
+
+/*
+Writes a user -> repo graph in the format matching the output file extension (GraphML, GEXF,
+Matrix Market, Pajek, METIS, SNAP, Parquet or Arrow IPC), returning whether the extension matched any of them.
+*/
+func formatOutput[U any](outputFile string, outputGraph graph.Graph[uint32, U], userLabel, repoLabel graph.Labeler[uint32]) bool {
+	var err error
+	switch filepath.Ext(outputFile) {
+	case ".graphml":
+		err = graph.WriteGraphML(outputFile, outputGraph, graph.UserNode, graph.RepoNode, userLabel, repoLabel)
+	case ".gexf":
+		err = graph.WriteGEXF(outputFile, outputGraph, graph.UserNode, graph.RepoNode, userLabel, repoLabel)
+	case ".mtx":
+		err = graph.WriteMatrixMarket(outputFile, outputGraph, graph.UserNode, graph.RepoNode)
+	case ".net":
+		err = graph.WritePajek(outputFile, outputGraph, graph.UserNode, graph.RepoNode, userLabel, repoLabel)
+	case ".metis":
+		if weighted, ok := any(outputGraph).(graph.Graph[uint32, uint32]); ok {
+			err = graph.WriteWeightedMETIS(outputFile, weighted, graph.UserNode, graph.RepoNode)
+		} else {
+			err = graph.WriteMETIS(outputFile, outputGraph, graph.UserNode, graph.RepoNode)
+		}
+	case ".parquet":
+		err = graph.WriteParquetEdges(outputFile, outputGraph)
+	case ".arrow", ".arrows":
+		err = graph.WriteArrowEdges(outputFile, outputGraph)
+	case ".snap":
+		err = graph.WriteSNAP(outputFile, outputGraph, graph.UserNode, graph.RepoNode, "GitHub user -> repo collaboration graph")
+	default:
+		return false
+	}
+	checkOutput(outputFile, err)
+	return true
+}
+
 func getMemoryUsage() string {
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
@@ -74,6 +337,36 @@ func main() {
     inputType := flag.String("t", "file", "the type of input (http/file)\ndefault is file")
     flag.StringVar(inputType, "type", "file", "the type of input (http/file)\ndefualt is file")
 
+    validate := flag.Bool("validate", false, "reject events missing actor, repo, type or created_at")
+    quarantine := flag.String("quarantine", "", "NDJSON file to write rejected lines into")
+
+    dictFile := flag.String("dict", "", "dictionary file (see the dictionary action) used to label graph outputs with logins and repo names")
+
+    canonical := flag.Bool("canonical", false, "key repos by their canonical identity, resolving renames and transfers (requires -dict)")
+    links := flag.String("links", "", "file of repos to merge under -canonical, a \"from-id to-id\" pair or a reused repo name per line")
+
+    excludeBots := flag.Bool("exclude-bots", false, "drop events of actors whose login marks them as bots at parse time")
+    botAllow := flag.String("bot-allow", "", "file of logins that are never classified as bots")
+    botDeny := flag.String("bot-deny", "", "file of logins that are always classified as bots")
+    botLabels := flag.String("bot-labels", "", "file written by the bots action, whose bots are removed from collab, pull request and issue graphs")
+
+    weights := flag.String("weights", "", "weight table for typeWeightedCollabGraph, e.g. \"PushEvent=3,PullRequestEvent=5,*=1\"")
+
+    windowName := flag.String("window", "weekly", "window of windowedCollabGraph (daily/weekly/monthly)")
+    split := flag.Bool("split", false, "write windowedCollabGraph as a file per window instead of a window column")
+
+    perRepo := flag.Bool("per-repo", false, "scope user -> user graphs per repo, writing a leading repo column")
+
+    salt := flag.String("salt", "", "secret key commit author emails are hashed with (HMAC-SHA256), required by the commit author actions")
+
+    dense := flag.Bool("dense", false, "remap ids to dense indices in collabGraphBinary, writing the mapping next to the output as .ids")
+
+    graphFile := flag.Bool("graph-file", false, "write collabGraphBinary in the indexed, memory mappable graph file format")
+
+    sorted := flag.Bool("sorted", false, "write collabGraph, collabGraphBinary and weightedCollabGraph sorted by node and neighbor, for reproducible outputs (-dense and -graph-file outputs are always sorted)")
+
+    threshold := flag.Float64("presence-threshold", 0.05, "minimal presence rate shift reported by schemaDiff")
+
     // Parse flags
     flag.Parse()
 
@@ -87,21 +380,279 @@ func main() {
         }
     }()
 
+	if (*split && *output == "") {
+		fail("-split requires an output file given by -o\n")
+		os.Exit(1)
+	}
 	if (*output == "") {
 		*output = os.DevNull
 		fmt.Println("WARNING: no output file given, output will be directed to /dev/null")
 	}
 
+	options := myjson.ParseOptions{Validate: *validate, QuarantineFile: *quarantine}
+	config := botConfig(*botAllow, *botDeny)
+	if (*excludeBots) {
+		options.EventFilters = append(options.EventFilters, bots.LoginFilter(config))
+	}
+	var excluded map[uint32]struct{}
+	if (*botLabels != "") {
+		var err error
+		if excluded, err = bots.ReadBots(*botLabels); err != nil {
+			fail("Error reading bot labels: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var dict *dictionary.Dictionary
+	if (*dictFile != "") {
+		var err error
+		dict, err = dictionary.Read(*dictFile)
+		if (err != nil) {
+			fail("Error reading dictionary: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	var resolver *dictionary.Resolver
+	var actorLabel, repoLabel graph.Labeler[uint32]
+	if (dict != nil) {
+		actorLabel = dict.ActorLabel
+		repoLabel = dict.RepoLabel
+	}
+	if (*canonical) {
+		if (!canonicalActions[*action]) {
+			fail("-canonical is not supported by the %v action\n", *action)
+			os.Exit(1)
+		}
+		if (dict == nil) {
+			fail("-canonical requires a dictionary given by -dict\n")
+			os.Exit(1)
+		}
+		var err error
+		if resolver, err = canonicalResolver(dict, *links); err != nil {
+			fail("Error reading links: %v\n", err)
+			os.Exit(1)
+		}
+		repoLabel = resolver.Label
+	} else if (*links != "") {
+		fail("-links requires -canonical\n")
+		os.Exit(1)
+	}
+	if (*dense && *graphFile) {
+		fail("-dense and -graph-file can't be combined\n")
+		os.Exit(1)
+	}
+
 	switch *action {
 		case "collabGraph":
-			outputGraph := collabGraph(files, *inputType)
-			graph.NeighborOutputGraph(*output, outputGraph)
+			outputGraph := collabGraph(files, *inputType, options, resolver)
+			bots.Exclude(outputGraph, excluded)
+			if (formatOutput(*output, outputGraph, actorLabel, repoLabel)) {
+				break
+			}
+			if (dict != nil && *sorted) {
+				checkOutput(*output, graph.SortedLabeledNeighborOutputGraph(*output, outputGraph, dict.ActorLabel, repoLabel))
+			} else if (dict != nil) {
+				checkOutput(*output, graph.LabeledNeighborOutputGraph(*output, outputGraph, dict.ActorLabel, repoLabel))
+			} else if (*sorted) {
+				checkOutput(*output, graph.SortedNeighborOutputGraph(*output, outputGraph))
+			} else {
+				checkOutput(*output, graph.NeighborOutputGraph(*output, outputGraph))
+			}
+		case "collabGraphBinary":
+			outputGraph := collabGraph(files, *inputType, options, resolver)
+			bots.Exclude(outputGraph, excluded)
+			var err error
+			if (*dense) {
+				err = graph.WriteNeighborGraphBinaryRemapped(*output, outputGraph, graph.UserNode, graph.RepoNode)
+			} else if (*graphFile) {
+				err = graph.WriteGraphFile(*output, graph.NewCSR(outputGraph, false))
+			} else if (*sorted) {
+				err = graph.WriteNeighborGraphBinarySorted(*output, outputGraph)
+			} else {
+				err = graph.WriteNeighborGraphBinary(*output, outputGraph)
+			}
+			if (err != nil) {
+				fail("Error writing binary graph: %v\n", err)
+			}
 		case "weightedCollabGraph":
-			outputGraph := weightedCollabGraph(files, *inputType)
-			graph.EdgeListOutputGraph(*output, outputGraph)
+			outputGraph := weightedCollabGraph(files, *inputType, options, resolver)
+			bots.Exclude(outputGraph, excluded)
+			if (formatOutput(*output, outputGraph, actorLabel, repoLabel)) {
+				break
+			}
+			if (dict != nil && *sorted) {
+				checkOutput(*output, graph.SortedLabeledEdgeListOutputGraph(*output, outputGraph, dict.ActorLabel, repoLabel))
+			} else if (dict != nil) {
+				checkOutput(*output, graph.LabeledEdgeListOutputGraph(*output, outputGraph, dict.ActorLabel, repoLabel))
+			} else if (*sorted) {
+				checkOutput(*output, graph.SortedEdgeListOutputGraph(*output, outputGraph))
+			} else {
+				checkOutput(*output, graph.EdgeListOutputGraph(*output, outputGraph))
+			}
+		case "weightedCollabGraphBinary":
+			outputGraph := weightedCollabGraph(files, *inputType, options, resolver)
+			bots.Exclude(outputGraph, excluded)
+			if err := graph.WriteWeightedNeighborGraphBinary(*output, outputGraph); err != nil {
+				fail("Error writing binary graph: %v\n", err)
+			}
+		case "typedCollabGraph":
+			outputGraph := typedCollabGraph(files, *inputType, options)
+			bots.Exclude(outputGraph, excluded)
+			checkOutput(*output, graph.RelationEdgeListOutputGraph[uint32, uint32](*output, outputGraph))
+		case "typeWeightedCollabGraph":
+			table := collabgraph.DefaultWeightTable()
+			if (*weights != "") {
+				var err error
+				if table, err = collabgraph.ParseWeightTable(*weights); err != nil {
+					fail("Error parsing weight table: %v\n", err)
+					os.Exit(1)
+				}
+			}
+			outputGraph := tableWeightedCollabGraph(files, *inputType, options, table)
+			bots.Exclude(outputGraph, excluded)
+			checkOutput(*output, graph.EdgeListOutputGraph(*output, outputGraph))
+		case "temporalCollabGraph":
+			outputGraph := temporalCollabGraph(files, *inputType, options)
+			bots.Exclude(outputGraph, excluded)
+			if (filepath.Ext(*output) == ".parquet") {
+				checkOutput(*output, graph.WriteTemporalParquetEdges(*output, outputGraph))
+			} else if (filepath.Ext(*output) == ".arrow" || filepath.Ext(*output) == ".arrows") {
+				checkOutput(*output, graph.WriteTemporalArrowEdges(*output, outputGraph))
+			} else {
+				checkOutput(*output, graph.TemporalEdgeListOutputGraph(*output, outputGraph))
+			}
+		case "windowedCollabGraph":
+			window, err := graph.ParseWindow(*windowName)
+			if (err != nil) {
+				fail("Error parsing window: %v\n", err)
+				os.Exit(1)
+			}
+			snapshots := windowedCollabGraph(files, *inputType, options, window)
+			for _, outputGraph := range snapshots.Graphs {
+				bots.Exclude(outputGraph, excluded)
+			}
+			if (*split) {
+				if _, err := graph.SnapshotEdgeListOutputFiles(*output, snapshots); err != nil {
+					fail("Error writing snapshots: %v\n", err)
+				}
+			} else {
+				checkOutput(*output, graph.SnapshotEdgeListOutputGraph(*output, snapshots))
+			}
+		case "forkGraph":
+			forest := forkForest(files, *inputType, options)
+			checkOutput(*output, graph.EdgeListOutputGraph(*output, forest.Children))
+		case "forkTrees":
+			forest := forkForest(files, *inputType, options)
+			if err := collabgraph.ForkTreesOutput(*output, forest); err != nil {
+				fail("Error writing fork trees: %v\n", err)
+			}
+		case "pullRequestGraph":
+			scope := collabgraph.GlobalScope
+			if (*perRepo) {
+				scope = collabgraph.RepoScope
+			}
+			graphs := pullRequestGraphs(files, *inputType, options, scope)
+			bots.ExcludeActors(graphs.Global, excluded)
+			for _, repoGraph := range graphs.PerRepo {
+				bots.ExcludeActors(repoGraph, excluded)
+			}
+			if (*perRepo) {
+				checkOutput(*output, graph.PartitionedRelationEdgeListOutputGraph[uint32, uint32, uint32](*output, graphs.PerRepo))
+			} else {
+				checkOutput(*output, graph.RelationEdgeListOutputGraph[uint32, uint32](*output, graphs.Global))
+			}
+		case "issueGraph":
+			graphs := issueGraphs(files, *inputType, options)
+			bots.ExcludeActors(graphs.Global, excluded)
+			for _, repoGraph := range graphs.PerRepo {
+				bots.ExcludeActors(repoGraph, excluded)
+			}
+			if (*perRepo) {
+				checkOutput(*output, graph.PartitionedEdgeListOutputGraph(*output, graphs.PerRepo))
+			} else {
+				checkOutput(*output, graph.EdgeListOutputGraph(*output, graphs.Global))
+			}
+		case "orgRepoGraph":
+			graphs := orgGraphs(files, *inputType, options)
+			checkOutput(*output, graph.NeighborOutputGraph(*output, graphs.Repos))
+		case "userOrgGraph":
+			graphs := orgGraphs(files, *inputType, options)
+			bots.Exclude(graphs.Users, excluded)
+			checkOutput(*output, graph.EdgeListOutputGraph(*output, graphs.Users))
+		case "orgOrgGraph":
+			graphs := orgGraphs(files, *inputType, options)
+			bots.Exclude(graphs.Users, excluded)
+			checkOutput(*output, graph.EdgeListOutputGraph(*output, graphs.SharedContributors()))
+		case "commitAuthorGraph":
+			graphs := commitAuthorGraphs(files, *inputType, options, *salt)
+			checkOutput(*output, graph.EdgeListOutputGraph(*output, graphs.AuthorRepos))
+		case "coAuthorGraph":
+			graphs := commitAuthorGraphs(files, *inputType, options, *salt)
+			checkOutput(*output, graph.EdgeListOutputGraph(*output, graphs.CoAuthors))
+		case "onBehalfGraph":
+			graphs := commitAuthorGraphs(files, *inputType, options, *salt)
+			checkOutput(*output, graph.EdgeListOutputGraph(*output, graphs.OnBehalf))
+		case "authorLinkGraph":
+			graphs := commitAuthorGraphs(files, *inputType, options, *salt)
+			checkOutput(*output, graph.EdgeListOutputGraph(*output, graphs.Links))
+		case "heteroGraph":
+			outputGraph := typedCollabGraph(files, *inputType, options)
+			bots.Exclude(outputGraph, excluded)
+			hetero := graph.FromRelationalGraph[uint32, uint32](outputGraph, graph.UserNode, graph.RepoNode)
+			checkOutput(*output, graph.HeteroEdgeListOutputGraph(*output, hetero))
+			if (dict != nil) {
+				labels := map[graph.NodeKind]graph.Labeler[uint64]{
+					graph.UserNode: func(id uint64) string { return dict.ActorLabel(uint32(id)) },
+					graph.RepoNode: func(id uint64) string { return repoLabel(uint32(id)) },
+				}
+				checkOutput(*output+".nodes", graph.HeteroNodeOutputGraph(*output+".nodes", hetero, labels))
+			}
+		case "eventsParquet", "eventsArrow":
+			manager := myjson.ParquetEventManeger(*output)
+			if (*action == "eventsArrow") {
+				manager = myjson.ArrowEventManeger(*output)
+			}
+			parsed, err := parse(files, manager, *inputType, options)
+			if (err == nil) {
+				err = parsed
+			}
+			if (err != nil) {
+				fail("Error writing events: %v\n", err)
+			}
+		case "dictionary":
+			dict := buildDictionary(files, *inputType, options)
+			if err := dictionary.Write(*output, dict); err != nil {
+				fail("Error writing dictionary: %v\n", err)
+			}
+		case "bots":
+			labels := classifyBots(files, *inputType, options, config)
+			if err := bots.WriteLabels(*output, labels); err != nil {
+				fail("Error writing bot labels: %v\n", err)
+			}
+		case "inferSchema":
+			schemas := inferSchemas(files, *inputType, options)
+			if err := infer.WriteSchemas(*output, schemas); err != nil {
+				fail("Error writing schemas: %v\n", err)
+			}
+		case "jsonSchema":
+			schemas := inferSchemas(files, *inputType, options)
+			if err := infer.WriteJSONSchema(*output, schemas); err != nil {
+				fail("Error writing json schema: %v\n", err)
+			}
+		case "schemaDiff":
+			schemaDiff(files, *threshold)
+		case "convertBinary":
+			if (len(files) != 1) {
+				fail("convertBinary takes a single legacy binary graph\n")
+				break
+			}
+			if err := graph.ConvertLegacyBinary[uint32](files[0], *output); err != nil {
+				fail("Error converting binary graph: %v\n", err)
+			}
 		default:
-			fmt.Println("Action not found")
-			return
+			fail("Action not found: %q\n", *action)
 	}
+	os.Exit(exitCode)
 }
 
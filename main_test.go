@@ -0,0 +1,75 @@
+package main
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"stream-parser/graph"
+	"stream-parser/myjson"
+	"stream-parser/myjson/dictionary"
+	"testing"
+	"time"
+)
+
+func writeEvents(t *testing.T, filename string, lines ...string) {
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	for _, line := range lines {
+		gz.Write([]byte(line + "\n"))
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A repo deleted and recreated under the same name is merged by the links file.
+func TestCanonicalLinks(t *testing.T) {
+	dir := t.TempDir()
+	day := func(d int) int64 { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC).Unix() }
+	dict := dictionary.New()
+	dict.Repos.Add(10, "alice/tool", day(1))
+	dict.Repos.Add(11, "alice/tool", day(20))
+	dict.Repos.Add(12, "bob/other", day(1))
+	dictFile := filepath.Join(dir, "dict.bin")
+	if err := dictionary.Write(dictFile, dict); err != nil {
+		t.Fatal(err)
+	}
+	linksFile := filepath.Join(dir, "links.txt")
+	if err := os.WriteFile(linksFile, []byte("# recreated\nalice/tool\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	events := filepath.Join(dir, "events.json.gz")
+	writeEvents(t, events,
+		`{"created_at":"2025-01-02T00:00:00Z","actor":{"id":1},"repo":{"id":10,"name":"alice/tool"}}`,
+		`{"created_at":"2025-01-21T00:00:00Z","actor":{"id":2},"repo":{"id":11,"name":"alice/tool"}}`,
+		`{"created_at":"2025-01-21T00:00:00Z","actor":{"id":2},"repo":{"id":12,"name":"bob/other"}}`,
+	)
+
+	read, err := dictionary.Read(dictFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver, err := canonicalResolver(read, linksFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := collabGraph([]string{events}, "file", myjson.ParseOptions{}, resolver)
+	want := graph.Graph[uint32, struct{}]{1: {11: {}}, 2: {11: {}, 12: {}}}
+	if len(got) != len(want) || len(got[1]) != 1 || len(got[2]) != 2 {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if _, ok := got[1][11]; !ok {
+		t.Errorf("expected repo 10 to be merged into 11, got %v", got)
+	}
+	if label := resolver.Label(10); label != "alice/tool" {
+		t.Errorf("expected a single identity for alice/tool, got label %v", label)
+	}
+
+	if _, err := canonicalResolver(read, filepath.Join(dir, "missing.txt")); err == nil {
+		t.Error("expected an error for a missing links file")
+	}
+}
@@ -0,0 +1,68 @@
+package bots
+
+import (
+	"time"
+)
+
+// Simillar to BaseEvent, slimmed to what the heuristics need.
+type activityEvent struct {
+	CreatedAt string        `json:"created_at"`
+	Actor     activityActor `json:"actor"`
+	Repo      activityRepo  `json:"repo"`
+}
+
+type activityActor struct {
+	ID    uint32 `json:"id"`
+	Login string `json:"login"`
+}
+
+type activityRepo struct {
+	ID uint32 `json:"id"`
+}
+
+// The activity of a single actor.
+type ActorActivity struct {
+	Login  string
+	Events uint32
+	Repos  map[uint32]struct{}
+	Hours  map[int32]uint32 // events per hour since the epoch
+}
+
+type Activity map[uint32]*ActorActivity
+
+// Average number of events in the hours the actor was active.
+func (a *ActorActivity) EventsPerHour() float64 {
+	if len(a.Hours) == 0 {
+		return 0
+	}
+	return float64(a.Events) / float64(len(a.Hours))
+}
+
+/*
+Collects the activity of every actor, to be classified by Classify.
+*/
+func ActivityManeger(in <-chan activityEvent) Activity {
+	activity := make(Activity)
+	for entry := range in {
+		actor, ok := activity[entry.Actor.ID]
+		if !ok {
+			actor = &ActorActivity{
+				Repos: make(map[uint32]struct{}),
+				Hours: make(map[int32]uint32),
+			}
+			activity[entry.Actor.ID] = actor
+		}
+		actor.Login = entry.Actor.Login
+		actor.Events++
+		actor.Repos[entry.Repo.ID] = struct{}{}
+		if t, err := time.Parse(time.RFC3339, entry.CreatedAt); err == nil {
+			actor.Hours[hourOf(t)]++
+		}
+	}
+	return activity
+}
+
+// Truncates a time to the hour index since the epoch, for counting active hours.
+func hourOf(t time.Time) int32 {
+	return int32(t.Unix() / 3600)
+}
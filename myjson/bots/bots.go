@@ -0,0 +1,245 @@
+/*
+Implements classification of bot and automation accounts.
+
+dependabot, github-actions and CI accounts interact with huge numbers of repos,
+creating hubs that distort any analysis of the collab graph. An actor is classified by,
+in order:
+  - The allow list, for accounts known to be people.
+  - The deny list, for known automation accounts.
+  - The "[bot]" login suffix GitHub gives to apps.
+  - Heuristics of its activity: events per active hour, and number of distinct repos.
+
+Actors can then be excluded at parse time (see LoginFilter, which only has the login
+to work with) or from a built graph (see Exclude and ExcludeActors).
+*/
+package bots
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"stream-parser/graph"
+	"stream-parser/myjson"
+	"strings"
+)
+
+const BotSuffix = "[bot]"
+
+// Reasons an actor was classified as it was.
+const (
+	ReasonAllowList   = "allow-list"
+	ReasonDenyList    = "deny-list"
+	ReasonLoginSuffix = "login-suffix"
+	ReasonRate        = "activity-rate"
+	ReasonBreadth     = "repo-breadth"
+)
+
+/*
+The configuration of the classification. a zero threshold disables its heuristic.
+The heuristics only apply to actors with at least MinEvents events.
+*/
+type Config struct {
+	Allow map[string]struct{} // logins that are never bots
+	Deny  map[string]struct{} // logins that are always bots
+
+	MinEvents        uint32
+	MaxEventsPerHour float64 // average events per active hour
+	MaxDistinctRepos int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Allow:            make(map[string]struct{}),
+		Deny:             make(map[string]struct{}),
+		MinEvents:        100,
+		MaxEventsPerHour: 60,
+		MaxDistinctRepos: 1000,
+	}
+}
+
+// The classification of an actor.
+type Label struct {
+	Login  string
+	Bot    bool
+	Reason string // empty for actors no rule applied to
+}
+
+type Labels map[uint32]Label
+
+/*
+Classifies by login alone, returning whether a rule applied.
+used both by Classify and at parse time, where the activity is unknown.
+*/
+func (c Config) classifyLogin(login string) (Label, bool) {
+	if _, ok := c.Allow[login]; ok {
+		return Label{Login: login, Bot: false, Reason: ReasonAllowList}, true
+	}
+	if _, ok := c.Deny[login]; ok {
+		return Label{Login: login, Bot: true, Reason: ReasonDenyList}, true
+	}
+	if strings.HasSuffix(login, BotSuffix) {
+		return Label{Login: login, Bot: true, Reason: ReasonLoginSuffix}, true
+	}
+	return Label{Login: login}, false
+}
+
+// Classifies every actor of the activity.
+func Classify(activity Activity, config Config) Labels {
+	labels := make(Labels, len(activity))
+	for id, actor := range activity {
+		label, ok := config.classifyLogin(actor.Login)
+		if !ok && actor.Events >= config.MinEvents {
+			switch {
+			case config.MaxEventsPerHour > 0 && actor.EventsPerHour() > config.MaxEventsPerHour:
+				label.Bot, label.Reason = true, ReasonRate
+			case config.MaxDistinctRepos > 0 && len(actor.Repos) > config.MaxDistinctRepos:
+				label.Bot, label.Reason = true, ReasonBreadth
+			}
+		}
+		labels[id] = label
+	}
+	return labels
+}
+
+// The ids of the actors labeled as bots.
+func (l Labels) Bots() map[uint32]struct{} {
+	bots := make(map[uint32]struct{})
+	for id, label := range l {
+		if label.Bot {
+			bots[id] = struct{}{}
+		}
+	}
+	return bots
+}
+
+/*
+An event filter for myjson.ParseOptions dropping events of actors whose login alone
+classifies them as bots (deny list and suffix, unless allowed). the login is read from
+the decoded event (see myjson.ActorLogin), events whose type holds no login are kept.
+*/
+func LoginFilter(config Config) myjson.EventFilter {
+	return func(event any) bool {
+		login, ok := myjson.ActorLogin(event)
+		if !ok {
+			return true
+		}
+		label, _ := config.classifyLogin(login)
+		return !label.Bot
+	}
+}
+
+/*
+Removes the bots from a graph of actor -> neighbors, such as the collab graph, in place.
+the neighbors are not actors, so only sources are removed, see ExcludeActors.
+returns the number of actors removed.
+*/
+func Exclude[U any](g graph.Graph[uint32, U], bots map[uint32]struct{}) int {
+	removed := 0
+	for id := range bots {
+		if _, ok := g[id]; ok {
+			delete(g, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+/*
+Removes the bots from a graph of actor -> actor, such as the pull request graph, in place,
+both as sources and as targets. returns the number of actors removed.
+*/
+func ExcludeActors[U any](g graph.Graph[uint32, U], bots map[uint32]struct{}) int {
+	removed := make(map[uint32]struct{})
+	for id := range bots {
+		if _, ok := g[id]; ok {
+			delete(g, id)
+			removed[id] = struct{}{}
+		}
+	}
+	for _, neighbors := range g {
+		for target := range neighbors {
+			if _, ok := bots[target]; ok {
+				delete(neighbors, target)
+				removed[target] = struct{}{}
+			}
+		}
+	}
+	return len(removed)
+}
+
+/*
+Reads a list of logins, one per line. empty lines and lines starting with # are ignored.
+*/
+func ReadList(filename string) (map[string]struct{}, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	logins := make(map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		logins[line] = struct{}{}
+	}
+	return logins, scanner.Err()
+}
+
+/*
+Writes the bots of the labels, in the format id login reason. the file goes through
+a sink, so it is compressed by extension (see graph.CreateSink).
+*/
+func WriteLabels(outputFile string, labels Labels) error {
+	sink, err := graph.CreateSink(outputFile)
+	if err != nil {
+		return err
+	}
+	if err := WriteLabelsTo(sink.Writer, labels); err != nil {
+		sink.Close()
+		return fmt.Errorf("%v: %w", outputFile, err)
+	}
+	return sink.Close()
+}
+
+// Writes the bots of labels in the format of WriteLabels.
+func WriteLabelsTo(w io.Writer, labels Labels) error {
+	writer := graph.BufferedWriter(w)
+	for id, label := range labels {
+		if !label.Bot {
+			continue
+		}
+		if _, err := fmt.Fprintf(writer, "%d %s %s\n", id, label.Login, label.Reason); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
+
+// Reads the ids of the bots written by WriteLabels.
+func ReadBots(filename string) (map[uint32]struct{}, error) {
+	file, err := graph.OpenSource(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadBotsFrom(file)
+}
+
+// Reads the ids of the bots in the format of WriteLabels.
+func ReadBotsFrom(r io.Reader) (map[uint32]struct{}, error) {
+	bots := make(map[uint32]struct{})
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var id uint32
+		if _, err := fmt.Sscan(scanner.Text(), &id); err != nil {
+			continue // skip malformed lines
+		}
+		bots[id] = struct{}{}
+	}
+	return bots, scanner.Err()
+}
@@ -0,0 +1,69 @@
+package bots
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"stream-parser/graph"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	in := make(chan activityEvent, 1000)
+	for i := 0; i < 200; i++ {
+		// one hour of heavy activity
+		in <- activityEvent{CreatedAt: "2025-01-01T10:00:00Z", Actor: activityActor{1, "ci-runner"}, Repo: activityRepo{uint32(i % 3)}}
+		in <- activityEvent{CreatedAt: fmt.Sprintf("2025-01-%02dT%02d:00:00Z", i%28+1, i%24), Actor: activityActor{2, "alice"}, Repo: activityRepo{7}}
+	}
+	in <- activityEvent{CreatedAt: "2025-01-01T10:00:00Z", Actor: activityActor{3, "dependabot[bot]"}, Repo: activityRepo{7}}
+	in <- activityEvent{CreatedAt: "2025-01-01T10:00:00Z", Actor: activityActor{4, "renovate"}, Repo: activityRepo{7}}
+	close(in)
+
+	config := DefaultConfig()
+	config.Deny["renovate"] = struct{}{}
+	config.Allow["ci-runner"] = struct{}{}
+	activity := ActivityManeger(in)
+	labels := Classify(activity, config)
+
+	expected := map[uint32]Label{
+		1: {Login: "ci-runner", Bot: false, Reason: ReasonAllowList},
+		2: {Login: "alice"},
+		3: {Login: "dependabot[bot]", Bot: true, Reason: ReasonLoginSuffix},
+		4: {Login: "renovate", Bot: true, Reason: ReasonDenyList},
+	}
+	for id, label := range expected {
+		if labels[id] != label {
+			t.Errorf("actor %d: expected %+v, got %+v", id, label, labels[id])
+		}
+	}
+
+	delete(config.Allow, "ci-runner")
+	if label := Classify(activity, config)[1]; !label.Bot || label.Reason != ReasonRate {
+		t.Errorf("expected ci-runner to be a bot by its rate, got %+v", label)
+	}
+
+	g := graph.Graph[uint32, struct{}]{1: {7: {}}, 3: {7: {}}, 4: {7: {}}}
+	if removed := Exclude(g, labels.Bots()); removed != 2 || len(g) != 1 {
+		t.Errorf("expected 2 bots removed, got %d leaving %v", removed, g)
+	}
+	actors := graph.Graph[uint32, uint32]{1: {3: 1, 2: 1}, 3: {1: 2}, 2: {4: 1}}
+	if removed := ExcludeActors(actors, labels.Bots()); removed != 2 || !reflect.DeepEqual(actors, graph.Graph[uint32, uint32]{1: {2: 1}, 2: {}}) {
+		t.Errorf("expected bots removed as sources and targets, got %d leaving %v", removed, actors)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteLabelsTo(&buf, labels); err != nil {
+		t.Fatal(err)
+	}
+	if bots, err := ReadBotsFrom(&buf); err != nil || !reflect.DeepEqual(bots, labels.Bots()) {
+		t.Errorf("expected the bots back, got %v %v", bots, err)
+	}
+
+	keep := LoginFilter(config)
+	if keep(&activityEvent{Actor: activityActor{3, "dependabot[bot]"}}) || !keep(&activityEvent{Actor: activityActor{2, "alice"}}) {
+		t.Errorf("unexpected login filter results")
+	}
+	if !keep(&struct{ Actor struct{ ID uint32 } }{}) {
+		t.Errorf("events holding no login should be kept")
+	}
+}
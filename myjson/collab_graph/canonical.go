@@ -0,0 +1,71 @@
+package collabgraph
+
+/*
+Implements collab graph managers keyed by canonical repo identity (see dictionary.Resolver).
+*/
+
+import (
+	"stream-parser/graph"
+	"stream-parser/myjson/dictionary"
+	"time"
+)
+
+// Simillar to slimEvent, with the repo name and time needed for resolution.
+type resolvableEvent struct {
+	CreatedAt string         `json:"created_at"`
+	Actor     slimActor      `json:"actor"`
+	Repo      resolvableRepo `json:"repo"`
+}
+
+type resolvableRepo struct {
+	ID   uint32 `json:"id"`
+	Name string `json:"name"`
+}
+
+func (e resolvableEvent) canonicalRepo(resolver *dictionary.Resolver) (uint32, bool) {
+	var at time.Time
+	if e.Repo.ID == 0 {
+		at, _ = time.Parse(time.RFC3339, e.CreatedAt) // only needed to resolve by name
+	}
+	return resolver.Resolve(e.Repo.ID, e.Repo.Name, at)
+}
+
+/*
+Same as CollabGraphManeger, but repos are keyed by their canonical identity, so linked
+repos are merged and events missing the repo id are resolved by name.
+events whose repo can't be resolved are dropped.
+*/
+func CanonicalCollabGraphManeger(resolver *dictionary.Resolver) func(<-chan resolvableEvent) graph.Graph[uint32, struct{}] {
+	return func(in <-chan resolvableEvent) graph.Graph[uint32, struct{}] {
+		graph := make(graph.Graph[uint32, struct{}])
+		for entry := range in {
+			repo, ok := entry.canonicalRepo(resolver)
+			if !ok {
+				continue
+			}
+			if graph[entry.Actor.ID] == nil {
+				graph[entry.Actor.ID] = make(map[uint32]struct{})
+			}
+			graph[entry.Actor.ID][repo] = struct{}{}
+		}
+		return graph
+	}
+}
+
+// A weighted version of the CanonicalCollabGraphManeger, see WeightedCollabGraphManeger.
+func CanonicalWeightedCollabGraphManeger(resolver *dictionary.Resolver) func(<-chan resolvableEvent) graph.Graph[uint32, uint32] {
+	return func(in <-chan resolvableEvent) graph.Graph[uint32, uint32] {
+		graph := make(graph.Graph[uint32, uint32])
+		for entry := range in {
+			repo, ok := entry.canonicalRepo(resolver)
+			if !ok {
+				continue
+			}
+			if graph[entry.Actor.ID] == nil {
+				graph[entry.Actor.ID] = make(map[uint32]uint32)
+			}
+			graph[entry.Actor.ID][repo] += 1
+		}
+		return graph
+	}
+}
@@ -18,6 +18,7 @@ type slimEvent struct {
 
 type slimActor struct {
     ID           uint32    `json:"id"`
+    Login        string    `json:"login"` // for bots.LoginFilter, see myjson.ActorLogin
 }
 
 type slimRepo struct {
@@ -1,13 +1,298 @@
 package collabgraph
 
-import "testing"
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"stream-parser/graph"
+	"strings"
+	"testing"
+	"time"
 
+	jsoniter "github.com/json-iterator/go"
+)
 
-func BenchmarkCollabGraphAction(b *testing.B) {
-	data := []byte(`{"actor":{"id":1},"repo":{"id":2}}`)
 
-	b.ReportAllocs() // 👈 tells Go to measure allocations
+// Unmarshals b.N events into in, the way ParseInParallel feeds the managers.
+func benchmarkEvents[T any](b *testing.B, in chan<- T) {
+	lines := make([][]byte, 1024)
+	for i := range lines {
+		lines[i] = fmt.Appendf(nil, `{"type":"%s","actor":{"id":%d},"repo":{"id":%d}}`, eventTypeNames[i%3], i%97, i%89)
+	}
 	for i := 0; i < b.N; i++ {
-		_, _ = CollabGraphAction(data)
+		var event T
+		if err := jsoniter.ConfigFastest.Unmarshal(lines[i%len(lines)], &event); err != nil {
+			b.Error(err)
+		}
+		in <- event
+	}
+	close(in)
+}
+
+func BenchmarkCollabGraphManeger(b *testing.B) {
+	in := make(chan slimEvent, 1024)
+	b.ReportAllocs() // 👈 tells Go to measure allocations
+	go benchmarkEvents(b, in)
+	CollabGraphManeger(in)
+}
+
+func BenchmarkTypedCollabGraphManeger(b *testing.B) {
+	in := make(chan typedEvent, 1024)
+	b.ReportAllocs()
+	go benchmarkEvents(b, in)
+	TypedCollabGraphManeger(in)
+}
+
+// Feeds the events into a channel, the way ParseInParallel does.
+func feed[T any](events ...T) <-chan T {
+	in := make(chan T, len(events))
+	for _, event := range events {
+		in <- event
+	}
+	close(in)
+	return in
+}
+
+func TestTypedCollabGraphManeger(t *testing.T) {
+	events := []typedEvent{
+		{Type: "PushEvent", Actor: slimActor{ID: 1}, Repo: slimRepo{2}},
+		{Type: "PushEvent", Actor: slimActor{ID: 1}, Repo: slimRepo{2}},
+		{Type: "WatchEvent", Actor: slimActor{ID: 1}, Repo: slimRepo{2}},
+		{Type: "SomeFutureEvent", Actor: slimActor{ID: 1}, Repo: slimRepo{3}},
+	}
+	typed := TypedCollabGraphManeger(feed(events...))
+	counts := typed[1][2]
+	if counts.Get(EventPush) != 2 || counts.Get(EventWatch) != 1 || counts.Total() != 3 {
+		t.Errorf("unexpected counts %v", counts)
+	}
+	if typed[1][3].Get(EventOther) != 1 {
+		t.Errorf("expected unknown types to be counted as other, got %v", typed[1][3])
+	}
+
+	// a third type spills into a count per type, keeping the counts and their order
+	var spilled EventCounts
+	for _, eventType := range []EventType{EventWatch, EventPush, EventWatch, EventFork, EventPush} {
+		spilled.Add(eventType, 1)
+	}
+	var relations []string
+	spilled.Relations(func(relation string, weight uint32) {
+		relations = append(relations, fmt.Sprintf("%s=%d", relation, weight))
+	})
+	if !reflect.DeepEqual(relations, []string{"ForkEvent=1", "PushEvent=2", "WatchEvent=2"}) || spilled.Total() != 5 {
+		t.Errorf("unexpected spilled counts %v", relations)
+	}
+
+	table, err := ParseWeightTable("*=1, PushEvent=3, WatchEvent=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	weighted := TableWeightedCollabGraphManeger(table)(feed(events...))
+	if weighted[1][2] != 6 || weighted[1][3] != 1 {
+		t.Errorf("unexpected weights %v", weighted)
+	}
+
+	if _, err := ParseWeightTable("PushEvent"); err == nil {
+		t.Errorf("expected an error for an entry with no weight")
+	}
+}
+
+func TestTemporalCollabGraphManeger(t *testing.T) {
+	temporal := TemporalCollabGraphManeger(feed(
+		timedEvent{CreatedAt: "2025-01-02T10:00:00Z", Actor: slimActor{ID: 1}, Repo: slimRepo{2}},
+		timedEvent{CreatedAt: "2025-01-01T23:00:00Z", Actor: slimActor{ID: 1}, Repo: slimRepo{2}},
+		timedEvent{CreatedAt: "2025-01-02T11:00:00Z", Actor: slimActor{ID: 1}, Repo: slimRepo{2}},
+		timedEvent{CreatedAt: "not a time", Actor: slimActor{ID: 1}, Repo: slimRepo{2}},
+		timedEvent{CreatedAt: "2025-01-05T00:00:00Z", Actor: slimActor{ID: 1}, Repo: slimRepo{3}},
+		timedEvent{CreatedAt: "2025-01-03T00:00:00Z", Actor: slimActor{ID: 1}, Repo: slimRepo{3}},
+		timedEvent{CreatedAt: "2025-01-05T12:00:00Z", Actor: slimActor{ID: 1}, Repo: slimRepo{3}},
+		timedEvent{CreatedAt: "2025-01-04T00:00:00Z", Actor: slimActor{ID: 1}, Repo: slimRepo{3}},
+		timedEvent{CreatedAt: "2025-01-07T01:00:00Z", Actor: slimActor{ID: 1}, Repo: slimRepo{4}},
+		timedEvent{CreatedAt: "2025-01-07T02:00:00Z", Actor: slimActor{ID: 1}, Repo: slimRepo{4}},
+	))
+	if temporal[1][3].ActiveDays != 3 || temporal[1][3].Count != 4 || temporal[1][4].ActiveDays != 1 {
+		t.Errorf("unexpected active days %v %v", temporal[1][3], temporal[1][4])
+	}
+
+	edge := temporal[1][2]
+	first, _ := time.Parse(time.RFC3339, "2025-01-01T23:00:00Z")
+	last, _ := time.Parse(time.RFC3339, "2025-01-02T11:00:00Z")
+	expected := graph.TemporalEdge{First: first.Unix(), Last: last.Unix(), Count: 3, ActiveDays: 2}
+	if edge != expected {
+		t.Errorf("expected %v, got %v", expected, edge)
+	}
+
+	filename := filepath.Join(t.TempDir(), "temporal.txt")
+	graph.TemporalEdgeListOutputGraph(filename, temporal)
+	read, err := graph.ReadTemporalEdgeList[uint32](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read[1][2] != expected {
+		t.Errorf("round trip: expected %v, got %v", expected, read[1][2])
+	}
+}
+
+func TestWindowedCollabGraphManeger(t *testing.T) {
+	snapshots := WindowedCollabGraphManeger(graph.Weekly)(feed(
+		timedEvent{CreatedAt: "2025-01-06T00:00:00Z", Actor: slimActor{ID: 1}, Repo: slimRepo{2}}, // monday
+		timedEvent{CreatedAt: "2025-01-12T23:59:59Z", Actor: slimActor{ID: 1}, Repo: slimRepo{2}}, // sunday
+		timedEvent{CreatedAt: "2025-01-13T00:00:00Z", Actor: slimActor{ID: 1}, Repo: slimRepo{3}},
+	))
+
+	starts := snapshots.Starts()
+	if len(starts) != 2 {
+		t.Fatalf("expected 2 windows, got %v", starts)
+	}
+	first, second := snapshots.Graphs[starts[0]], snapshots.Graphs[starts[1]]
+	if first[1][2] != 2 || second[1][3] != 1 || len(second[1]) != 1 {
+		t.Errorf("unexpected snapshots %v %v", first, second)
+	}
+	if label := graph.Weekly.Label(time.Unix(starts[0], 0)); label != "2025-01-06" {
+		t.Errorf("unexpected window label %v", label)
+	}
+
+	files, err := graph.SnapshotEdgeListOutputFiles(filepath.Join(t.TempDir(), "weekly.txt"), snapshots)
+	if err != nil || len(files) != 2 || filepath.Base(files[1]) != "weekly.2025-01-13.txt" {
+		t.Errorf("unexpected snapshot files %v", files)
+	}
+	files, err = graph.SnapshotEdgeListOutputFiles(filepath.Join(t.TempDir(), "weekly.txt.gz"), snapshots)
+	if err != nil || len(files) != 2 || filepath.Base(files[0]) != "weekly.2025-01-06.txt.gz" {
+		t.Errorf("unexpected compressed snapshot files %v", files)
+	}
+}
+
+func TestForkGraphManeger(t *testing.T) {
+	fork := func(parent, child uint32, createdAt string) forkEvent {
+		return forkEvent{Type: "ForkEvent", CreatedAt: createdAt, Actor: slimActor{ID: child * 10}, Repo: slimRepo{parent}, Payload: forkPayload{slimRepo{child}}}
+	}
+	forest := ForkGraphManeger(feed(
+		fork(1, 2, "2025-01-01T00:00:00Z"),
+		fork(1, 3, "2025-01-01T00:00:00Z"),
+		fork(3, 4, "2025-01-02T00:00:00Z"),
+		fork(5, 4, "2025-01-03T00:00:00Z"), // a later duplicate of fork 4 is ignored
+		forkEvent{Type: "PushEvent", Actor: slimActor{ID: 1}, Repo: slimRepo{1}},
+	))
+
+	if roots := forest.Roots(); len(roots) != 1 || roots[0] != 1 {
+		t.Errorf("unexpected roots %v", roots)
+	}
+	if stats := forest.Tree(1); stats != (TreeStats{Size: 4, Height: 2}) {
+		t.Errorf("unexpected tree stats %+v", stats)
+	}
+	if depth, root := forest.Depth(4), forest.Root(4); depth != 2 || root != 1 {
+		t.Errorf("expected fork 4 at depth 2 under 1, got %d under %d", depth, root)
+	}
+	if edge := forest.Children[3][4]; edge.Forker != 40 {
+		t.Errorf("unexpected fork edge %v", edge)
+	}
+
+	var trees strings.Builder
+	if err := WriteForkTrees(&trees, forest); err != nil || trees.String() != "1 4 2\n" {
+		t.Errorf("unexpected fork trees %q %v", trees.String(), err)
+	}
+}
+
+func TestPullRequestGraphManeger(t *testing.T) {
+	pr := func(eventType, action string, actor, repo, number uint32) pullRequestEvent {
+		return pullRequestEvent{Type: eventType, Actor: slimActor{ID: actor}, Repo: slimRepo{repo},
+			Payload: pullRequestPayload{Action: action, Number: number}}
+	}
+	merged := pr("PullRequestEvent", "closed", 3, 10, 1)
+	merged.Payload.PullRequest.Merged = true
+	merged.Payload.PullRequest.MergedBy = slimActor{ID: 4}
+
+	graphs := PullRequestGraphManeger(RepoScope)(feed(
+		pr("PullRequestReviewEvent", "created", 2, 10, 1), // before the author is known
+		pr("PullRequestEvent", "opened", 1, 10, 1),
+		pr("PullRequestReviewCommentEvent", "created", 2, 10, 1),
+		pr("PullRequestReviewCommentEvent", "created", 1, 10, 1), // self interaction
+		merged,
+		pr("PullRequestReviewEvent", "created", 2, 11, 7), // unknown author
+	))
+
+	expected := graph.Graph[uint32, PRInteraction]{
+		2: {1: {Reviews: 1, ReviewComments: 1}},
+		4: {1: {Merges: 1}},
+	}
+	if !reflect.DeepEqual(graphs.Global, expected) {
+		t.Errorf("expected %v, got %v", expected, graphs.Global)
+	}
+	if !reflect.DeepEqual(graphs.PerRepo[10], expected) || len(graphs.PerRepo) != 1 {
+		t.Errorf("unexpected per repo graphs %v", graphs.PerRepo)
+	}
+}
+
+func TestIssueGraphManeger(t *testing.T) {
+	issue := func(eventType, action string, actor, repo, number, author uint32) issueEvent {
+		return issueEvent{Type: eventType, Actor: slimActor{ID: actor}, Repo: slimRepo{repo},
+			Payload: issuePayload{Action: action, Issue: slimIssue{Number: number, User: slimActor{ID: author}}}}
+	}
+	graphs := IssueGraphManeger(feed(
+		issue("IssueCommentEvent", "created", 2, 10, 1, 0), // author known only from the opening
+		issue("IssuesEvent", "opened", 1, 10, 1, 0),
+		issue("IssueCommentEvent", "created", 2, 10, 1, 0),
+		issue("IssueCommentEvent", "created", 2, 11, 5, 1), // author from the payload
+		issue("IssueCommentEvent", "created", 1, 10, 1, 0), // own issue
+	))
+
+	if graphs.Global[2][1] != 3 || len(graphs.Global) != 1 {
+		t.Errorf("unexpected global graph %v", graphs.Global)
+	}
+	if graphs.PerRepo[10][2][1] != 2 || graphs.PerRepo[11][2][1] != 1 {
+		t.Errorf("unexpected per repo graphs %v", graphs.PerRepo)
+	}
+}
+
+func TestOrgGraphManeger(t *testing.T) {
+	graphs := OrgGraphManeger(feed(
+		orgEvent{Actor: slimActor{ID: 1}, Repo: slimRepo{10}, Org: slimOrg{100}},
+		orgEvent{Actor: slimActor{ID: 1}, Repo: slimRepo{20}, Org: slimOrg{200}},
+		orgEvent{Actor: slimActor{ID: 2}, Repo: slimRepo{11}, Org: slimOrg{100}},
+		orgEvent{Actor: slimActor{ID: 2}, Repo: slimRepo{21}, Org: slimOrg{200}},
+		orgEvent{Actor: slimActor{ID: 2}, Repo: slimRepo{21}, Org: slimOrg{200}},
+		orgEvent{Actor: slimActor{ID: 3}, Repo: slimRepo{30}}, // a user repo
+	))
+
+	if len(graphs.Repos[100]) != 2 || len(graphs.Repos) != 2 {
+		t.Errorf("unexpected org repos %v", graphs.Repos)
+	}
+	if graphs.Users[2][200] != 2 || graphs.Users[3] != nil {
+		t.Errorf("unexpected user activity %v", graphs.Users)
+	}
+	if shared := graphs.SharedContributors(); shared[200][100] != 2 || len(shared) != 1 {
+		t.Errorf("unexpected shared contributors %v", shared)
+	}
+}
+
+func TestCommitAuthorGraphManeger(t *testing.T) {
+	push := pushEvent{Type: "PushEvent", Actor: namedActor{1, "alice"}, Repo: slimRepo{10}, Payload: pushPayload{Commits: []slimCommit{
+		{Author: commitAuthor{Email: "alice@example.com", Name: "Alice"}},
+		{Author: commitAuthor{Email: "2+bob@users.noreply.github.com", Name: "Bob"},
+			Message: "fix\n\nCo-authored-by: Carol <Carol@Example.com>"},
+		{Author: commitAuthor{Email: "alice@home.example", Name: "Alice Smith"}}, // unlinked, not on behalf of anyone
+	}}}
+	graphs := CommitAuthorGraphManeger("salt")(feed(push, pushEvent{Type: "WatchEvent"}))
+
+	alice := HashEmail("salt", "alice@example.com")
+	bob := HashEmail("salt", "2+bob@users.noreply.github.com")
+	carol := HashEmail("salt", "carol@example.com")
+
+	if graphs.Commits != 3 || graphs.OnBehalfCommits != 1 || graphs.UnlinkedCommits != 1 {
+		t.Errorf("unexpected counts %d %d %d", graphs.Commits, graphs.OnBehalfCommits, graphs.UnlinkedCommits)
+	}
+	if HashEmail("salt", "alice@example.com") == HashEmail("other", "alice@example.com") {
+		t.Errorf("expected the hash to depend on the salt")
+	}
+	if graphs.Links[alice][1] != 1 || graphs.Links[bob][2] != 1 {
+		t.Errorf("unexpected links %v", graphs.Links)
+	}
+	if graphs.OnBehalf[1][bob] != 1 || len(graphs.OnBehalf[1]) != 1 {
+		t.Errorf("unexpected on behalf %v", graphs.OnBehalf)
+	}
+	if graphs.CoAuthors[max(bob, carol)][min(bob, carol)] != 1 {
+		t.Errorf("unexpected co-authors %v", graphs.CoAuthors)
+	}
+	if graphs.AuthorRepos[alice][10] != 1 {
+		t.Errorf("unexpected author repos %v", graphs.AuthorRepos)
 	}
 }
@@ -0,0 +1,157 @@
+package collabgraph
+
+/*
+Implements the commit author graphs from PushEvent payloads.
+
+The commits of a push carry their own author, distinct from the actor that pushed them,
+which exposes how much work is pushed on behalf of others. Authors are identified by
+a keyed hash (HMAC-SHA256) of their email, so no email is kept in memory or in the outputs.
+*/
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"regexp"
+	"strconv"
+	"stream-parser/graph"
+	"strings"
+)
+
+// Simillar to slimEvent, with the commits of a PushEvent.
+type pushEvent struct {
+	Type    string      `json:"type"`
+	Actor   namedActor  `json:"actor"`
+	Repo    slimRepo    `json:"repo"`
+	Payload pushPayload `json:"payload"`
+}
+
+type namedActor struct {
+	ID    uint32 `json:"id"`
+	Login string `json:"login"`
+}
+
+type pushPayload struct {
+	Commits []slimCommit `json:"commits"`
+}
+
+type slimCommit struct {
+	Author  commitAuthor `json:"author"`
+	Message string       `json:"message"`
+}
+
+type commitAuthor struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+/*
+Graphs over commit authors, identified by their email hash. since Graph holds a single
+node type, actor and repo ids are held as uint64 as well.
+*/
+type CommitAuthorGraphs struct {
+	AuthorRepos graph.Graph[uint64, uint32] // author -> repo, weighted by commits
+	CoAuthors   graph.Graph[uint64, uint32] // author <-> author by Co-authored-by trailers, held once as graph[larger][smaller]
+	Links       graph.Graph[uint64, uint32] // author -> actor the author was linked to, weighted by commits
+	OnBehalf    graph.Graph[uint64, uint32] // pushing actor -> author of commits it pushed on behalf of others
+
+	Commits         uint64
+	OnBehalfCommits uint64 // commits of authors linked to an actor other than the pusher
+	UnlinkedCommits uint64 // commits of authors linked to no actor, who may or may not be the pusher
+}
+
+var coAuthorTrailer = regexp.MustCompile(`(?im)^co-authored-by:\s*(.*?)\s*<([^>]+)>\s*$`)
+
+// The HMAC-SHA256 of an email keyed by salt, normalized to be case insensitive.
+func HashEmail(salt string, email string) uint64 {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return binary.LittleEndian.Uint64(mac.Sum(nil)[:8])
+}
+
+/*
+Returns the actor id of a GitHub noreply email, of the form id+login@users.noreply.github.com.
+*/
+func noreplyActor(email string) (uint32, bool) {
+	local, domain, ok := strings.Cut(strings.ToLower(email), "@")
+	if !ok || domain != "users.noreply.github.com" {
+		return 0, false
+	}
+	idPart, _, ok := strings.Cut(local, "+")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(idPart, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(id), true
+}
+
+/*
+Takes in events, and builds the commit author graphs out of the PushEvents among them.
+
+A commit author is linked to an actor by a noreply email holding the actor id, or
+when the author name is the login of the pusher. a commit is pushed on behalf of
+another if its author is linked to another actor. commits of unlinked authors are
+only counted, as they are mostly the pusher's own under another name or email.
+
+Parameters:
+  - salt		The key emails are hashed with, keep it secret to prevent dictionary attacks on the hashes.
+				an empty salt leaves the hashes reversible by anyone hashing known emails.
+*/
+func CommitAuthorGraphManeger(salt string) func(<-chan pushEvent) CommitAuthorGraphs {
+	return func(in <-chan pushEvent) CommitAuthorGraphs {
+		graphs := CommitAuthorGraphs{
+			AuthorRepos: make(graph.Graph[uint64, uint32]),
+			CoAuthors:   make(graph.Graph[uint64, uint32]),
+			Links:       make(graph.Graph[uint64, uint32]),
+			OnBehalf:    make(graph.Graph[uint64, uint32]),
+		}
+		for entry := range in {
+			if entry.Type != "PushEvent" {
+				continue
+			}
+			pusher := uint64(entry.Actor.ID)
+			for _, commit := range entry.Payload.Commits {
+				if commit.Author.Email == "" {
+					continue
+				}
+				author := HashEmail(salt, commit.Author.Email)
+				graphs.Commits++
+				addWeight(graphs.AuthorRepos, author, uint64(entry.Repo.ID))
+
+				actor, linked := noreplyActor(commit.Author.Email)
+				if !linked && strings.EqualFold(commit.Author.Name, entry.Actor.Login) {
+					actor, linked = entry.Actor.ID, true
+				}
+				if !linked {
+					graphs.UnlinkedCommits++
+				} else {
+					addWeight(graphs.Links, author, uint64(actor))
+					if actor != entry.Actor.ID {
+						graphs.OnBehalfCommits++
+						addWeight(graphs.OnBehalf, pusher, author)
+					}
+				}
+
+				for _, match := range coAuthorTrailer.FindAllStringSubmatch(commit.Message, -1) {
+					coAuthor := HashEmail(salt, match[2])
+					if coAuthor > author {
+						addWeight(graphs.CoAuthors, coAuthor, author)
+					} else if coAuthor < author {
+						addWeight(graphs.CoAuthors, author, coAuthor)
+					}
+				}
+			}
+		}
+		return graphs
+	}
+}
+
+func addWeight(g graph.Graph[uint64, uint32], src, target uint64) {
+	if g[src] == nil {
+		g[src] = make(map[uint64]uint32)
+	}
+	g[src][target] += 1
+}
@@ -0,0 +1,187 @@
+package collabgraph
+
+/*
+Implements the fork network, the forest of repo -> fork edges built from ForkEvent payloads.
+*/
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"stream-parser/graph"
+	"time"
+)
+
+// Simillar to slimEvent, with the fork created by a ForkEvent.
+type forkEvent struct {
+	Type      string      `json:"type"`
+	CreatedAt string      `json:"created_at"`
+	Actor     slimActor   `json:"actor"`
+	Repo      slimRepo    `json:"repo"`
+	Payload   forkPayload `json:"payload"`
+}
+
+type forkPayload struct {
+	Forkee slimRepo `json:"forkee"`
+}
+
+// The edge of a repo to its fork, who forked it and when (unix seconds).
+type ForkEdge struct {
+	Forker    uint32
+	CreatedAt int64
+}
+
+// Formats the edge as the columns forker createdAt, so edge-list writers output them.
+func (e ForkEdge) String() string {
+	return fmt.Sprintf("%d %s", e.Forker, time.Unix(e.CreatedAt, 0).UTC().Format(time.RFC3339))
+}
+
+type ForkForest struct {
+	Children graph.Graph[uint32, ForkEdge] // parent -> fork
+	Parent   map[uint32]uint32             // fork -> parent
+}
+
+// The size of a fork tree, in repos including the root, and its height (0 for a root with no forks).
+type TreeStats struct {
+	Size   int
+	Height int
+}
+
+/*
+Takes in events, and builds the fork forest out of the ForkEvents among them.
+if a fork is reported more than once, the earliest event is kept.
+*/
+func ForkGraphManeger(in <-chan forkEvent) ForkForest {
+	forest := ForkForest{
+		Children: make(graph.Graph[uint32, ForkEdge]),
+		Parent:   make(map[uint32]uint32),
+	}
+	for entry := range in {
+		if entry.Type != "ForkEvent" {
+			continue
+		}
+		parent, fork := entry.Repo.ID, entry.Payload.Forkee.ID
+		if fork == 0 || fork == parent {
+			continue
+		}
+		var createdAt int64
+		if t, err := time.Parse(time.RFC3339, entry.CreatedAt); err == nil {
+			createdAt = t.Unix()
+		}
+		edge := ForkEdge{Forker: entry.Actor.ID, CreatedAt: createdAt}
+
+		if previous, ok := forest.Parent[fork]; ok {
+			if forest.Children[previous][fork].CreatedAt <= createdAt {
+				continue
+			}
+			delete(forest.Children[previous], fork)
+		}
+		if forest.Children[parent] == nil {
+			forest.Children[parent] = make(map[uint32]ForkEdge)
+		}
+		forest.Children[parent][fork] = edge
+		forest.Parent[fork] = parent
+	}
+	return forest
+}
+
+/*
+The root of the tree repo belongs to. the walk is bounded by the number of forks,
+so a cycle in malformed data ends it instead of looping forever.
+*/
+func (f ForkForest) Root(repo uint32) uint32 {
+	for steps := 0; steps <= len(f.Parent); steps++ {
+		parent, ok := f.Parent[repo]
+		if !ok {
+			return repo
+		}
+		repo = parent
+	}
+	return repo
+}
+
+// The number of fork edges between repo and its root.
+func (f ForkForest) Depth(repo uint32) int {
+	depth := 0
+	for ; depth <= len(f.Parent); depth++ {
+		parent, ok := f.Parent[repo]
+		if !ok {
+			break
+		}
+		repo = parent
+	}
+	return depth
+}
+
+// The repos that were forked but are not forks themselves, sorted.
+func (f ForkForest) Roots() []uint32 {
+	roots := make([]uint32, 0)
+	for repo, forks := range f.Children {
+		if _, ok := f.Parent[repo]; !ok && len(forks) > 0 {
+			roots = append(roots, repo)
+		}
+	}
+	sort.Slice(roots, func(i, j int) bool { return roots[i] < roots[j] })
+	return roots
+}
+
+// The size and height of the tree rooted at root, walked breadth first.
+func (f ForkForest) Tree(root uint32) TreeStats {
+	stats := TreeStats{}
+	visited := map[uint32]struct{}{root: {}}
+	level := []uint32{root}
+	for len(level) > 0 {
+		stats.Size += len(level)
+		next := make([]uint32, 0)
+		for _, repo := range level {
+			for fork := range f.Children[repo] {
+				if _, ok := visited[fork]; ok {
+					continue
+				}
+				visited[fork] = struct{}{}
+				next = append(next, fork)
+			}
+		}
+		if len(next) > 0 {
+			stats.Height++
+		}
+		level = next
+	}
+	return stats
+}
+
+// The stats of every tree in the forest, by root.
+func (f ForkForest) Trees() map[uint32]TreeStats {
+	trees := make(map[uint32]TreeStats)
+	for _, root := range f.Roots() {
+		trees[root] = f.Tree(root)
+	}
+	return trees
+}
+
+// Outputs the trees of the forest, in the format root size height, largest trees first.
+func ForkTreesOutput(outputFile string, forest ForkForest) error {
+	writer, err := graph.CreateSink(outputFile)
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	if err := WriteForkTrees(writer, forest); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// Writes the trees of a forest in the format of ForkTreesOutput.
+func WriteForkTrees(w io.Writer, forest ForkForest) error {
+	trees := forest.Trees()
+	roots := forest.Roots()
+	sort.SliceStable(roots, func(i, j int) bool { return trees[roots[i]].Size > trees[roots[j]].Size })
+
+	for _, root := range roots {
+		if _, err := fmt.Fprintf(w, "%d %d %d\n", root, trees[root].Size, trees[root].Height); err != nil {
+			return err
+		}
+	}
+	return nil
+}
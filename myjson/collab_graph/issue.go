@@ -0,0 +1,100 @@
+package collabgraph
+
+/*
+Implements the issue discussion graph, linking commenters to the authors of the
+issues they comment on, for analyzing community support structures.
+*/
+
+import (
+	"stream-parser/graph"
+)
+
+// Simillar to slimEvent, with the parts of the issue payloads needed.
+type issueEvent struct {
+	Type    string       `json:"type"`
+	Actor   slimActor    `json:"actor"`
+	Repo    slimRepo     `json:"repo"`
+	Payload issuePayload `json:"payload"`
+}
+
+type issuePayload struct {
+	Action string    `json:"action"`
+	Issue  slimIssue `json:"issue"`
+}
+
+type slimIssue struct {
+	Number uint32    `json:"number"`
+	User   slimActor `json:"user"`
+}
+
+// Comment graphs of commenter -> issue author weighted by the number of comments.
+type IssueGraphs struct {
+	Global  graph.Graph[uint32, uint32]
+	PerRepo map[uint32]graph.Graph[uint32, uint32] // the same edges, annotated by the repo of the issue
+}
+
+type issueKey struct {
+	Repo   uint32
+	Number uint32
+}
+
+type issueComment struct {
+	issue     issueKey
+	commenter uint32
+}
+
+/*
+Takes in events, and builds the comment graph out of the IssuesEvent and IssueCommentEvent among them.
+
+The author is taken from the payload, or when missing from the actor that opened the issue.
+since events arrive out of order, comments are kept until all events are read.
+comments on ones own issue are dropped.
+*/
+func IssueGraphManeger(in <-chan issueEvent) IssueGraphs {
+	authors := make(map[issueKey]uint32)
+	comments := make([]issueComment, 0)
+
+	for entry := range in {
+		if entry.Payload.Issue.Number == 0 {
+			continue
+		}
+		key := issueKey{Repo: entry.Repo.ID, Number: entry.Payload.Issue.Number}
+		if author := entry.Payload.Issue.User.ID; author != 0 {
+			authors[key] = author
+		}
+
+		switch entry.Type {
+		case "IssuesEvent":
+			if entry.Payload.Action == "opened" {
+				if _, ok := authors[key]; !ok {
+					authors[key] = entry.Actor.ID
+				}
+			}
+		case "IssueCommentEvent":
+			comments = append(comments, issueComment{issue: key, commenter: entry.Actor.ID})
+		}
+	}
+
+	graphs := IssueGraphs{
+		Global:  make(graph.Graph[uint32, uint32]),
+		PerRepo: make(map[uint32]graph.Graph[uint32, uint32]),
+	}
+	for _, comment := range comments {
+		author, ok := authors[comment.issue]
+		if !ok || author == comment.commenter {
+			continue
+		}
+		repoGraph := graphs.PerRepo[comment.issue.Repo]
+		if repoGraph == nil {
+			repoGraph = make(graph.Graph[uint32, uint32])
+			graphs.PerRepo[comment.issue.Repo] = repoGraph
+		}
+		for _, g := range []graph.Graph[uint32, uint32]{graphs.Global, repoGraph} {
+			if g[comment.commenter] == nil {
+				g[comment.commenter] = make(map[uint32]uint32)
+			}
+			g[comment.commenter][author] += 1
+		}
+	}
+	return graphs
+}
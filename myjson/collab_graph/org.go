@@ -0,0 +1,73 @@
+package collabgraph
+
+/*
+Implements organization level aggregation, with org ids as nodes, for analyzing
+cross company collaboration on open source.
+*/
+
+import (
+	"stream-parser/graph"
+)
+
+// Simillar to slimEvent, with the org the repo belongs to (id 0 for repos of users).
+type orgEvent struct {
+	Actor slimActor `json:"actor"`
+	Repo  slimRepo  `json:"repo"`
+	Org   slimOrg   `json:"org"`
+}
+
+type slimOrg struct {
+	ID uint32 `json:"id"`
+}
+
+type OrgGraphs struct {
+	Repos graph.Graph[uint32, struct{}] // org -> repos of the org
+	Users graph.Graph[uint32, uint32]   // user -> orgs, weighted by the number of events
+}
+
+/*
+Takes in events, and builds the org membership and activity graphs.
+events on repos with no org are skipped.
+*/
+func OrgGraphManeger(in <-chan orgEvent) OrgGraphs {
+	graphs := OrgGraphs{
+		Repos: make(graph.Graph[uint32, struct{}]),
+		Users: make(graph.Graph[uint32, uint32]),
+	}
+	for entry := range in {
+		if entry.Org.ID == 0 {
+			continue
+		}
+		if graphs.Repos[entry.Org.ID] == nil {
+			graphs.Repos[entry.Org.ID] = make(map[uint32]struct{})
+		}
+		graphs.Repos[entry.Org.ID][entry.Repo.ID] = struct{}{}
+
+		if graphs.Users[entry.Actor.ID] == nil {
+			graphs.Users[entry.Actor.ID] = make(map[uint32]uint32)
+		}
+		graphs.Users[entry.Actor.ID][entry.Org.ID] += 1
+	}
+	return graphs
+}
+
+/*
+Builds the org <-> org graph, weighted by the number of contributors the two orgs share.
+as the graph is undirected, each pair is held once as graph[larger][smaller].
+*/
+func (g OrgGraphs) SharedContributors() graph.Graph[uint32, uint32] {
+	shared := make(graph.Graph[uint32, uint32])
+	for _, orgs := range g.Users {
+		for org := range orgs {
+			for otherOrg := range orgs {
+				if org > otherOrg { // Avoid multiple writes
+					if shared[org] == nil {
+						shared[org] = make(map[uint32]uint32)
+					}
+					shared[org][otherOrg] += 1
+				}
+			}
+		}
+	}
+	return shared
+}
@@ -0,0 +1,170 @@
+package collabgraph
+
+/*
+Implements the pull request interaction graph, a directed user -> user graph of who
+reviews and merges whose pull requests, for studying maintainer load and mentorship.
+*/
+
+import (
+	"stream-parser/graph"
+)
+
+// Simillar to slimEvent, with the parts of the pull request payloads needed.
+type pullRequestEvent struct {
+	Type    string             `json:"type"`
+	Actor   slimActor          `json:"actor"`
+	Repo    slimRepo           `json:"repo"`
+	Payload pullRequestPayload `json:"payload"`
+}
+
+type pullRequestPayload struct {
+	Action      string          `json:"action"`
+	Number      uint32          `json:"number"`
+	PullRequest slimPullRequest `json:"pull_request"`
+}
+
+type slimPullRequest struct {
+	Number   uint32    `json:"number"`
+	User     slimActor `json:"user"`
+	Merged   bool      `json:"merged"`
+	MergedBy slimActor `json:"merged_by"`
+}
+
+// The interactions of a user with the pull requests of another.
+type PRInteraction struct {
+	Reviews        uint32
+	ReviewComments uint32
+	Merges         uint32
+}
+
+// Implements graph.Relational, yielding the non zero interactions.
+func (p PRInteraction) Relations(yield func(relation string, weight uint32)) {
+	if p.Reviews != 0 {
+		yield("review", p.Reviews)
+	}
+	if p.ReviewComments != 0 {
+		yield("review_comment", p.ReviewComments)
+	}
+	if p.Merges != 0 {
+		yield("merge", p.Merges)
+	}
+}
+
+// Whether interactions are collected into a single graph, or into a graph per repo as well.
+type Scope int
+
+const (
+	GlobalScope Scope = iota
+	RepoScope
+)
+
+type PullRequestGraphs struct {
+	Global  graph.Graph[uint32, PRInteraction]
+	PerRepo map[uint32]graph.Graph[uint32, PRInteraction] // nil in GlobalScope
+}
+
+type pullRequestKey struct {
+	Repo   uint32
+	Number uint32
+}
+
+type prInteractionKind int
+
+const (
+	review prInteractionKind = iota
+	reviewComment
+	merge
+)
+
+type prInteraction struct {
+	pullRequest pullRequestKey
+	user        uint32
+	kind        prInteractionKind
+}
+
+/*
+Takes in events, and builds the graph user -> pull request author out of the
+PullRequestEvent, PullRequestReviewEvent and PullRequestReviewCommentEvent among them.
+
+The author is taken from the payload, or when missing (as in the slimmed payloads)
+from the actor that opened the pull request. since events arrive out of order,
+interactions are kept until all events are read. self interactions are dropped.
+*/
+func PullRequestGraphManeger(scope Scope) func(<-chan pullRequestEvent) PullRequestGraphs {
+	return func(in <-chan pullRequestEvent) PullRequestGraphs {
+		authors := make(map[pullRequestKey]uint32)
+		interactions := make([]prInteraction, 0)
+
+		for entry := range in {
+			number := entry.Payload.PullRequest.Number
+			if number == 0 {
+				number = entry.Payload.Number
+			}
+			if number == 0 {
+				continue
+			}
+			key := pullRequestKey{Repo: entry.Repo.ID, Number: number}
+			if author := entry.Payload.PullRequest.User.ID; author != 0 {
+				authors[key] = author
+			}
+
+			switch entry.Type {
+			case "PullRequestEvent":
+				switch entry.Payload.Action {
+				case "opened":
+					if _, ok := authors[key]; !ok {
+						authors[key] = entry.Actor.ID
+					}
+				case "closed":
+					if entry.Payload.PullRequest.Merged {
+						merger := entry.Payload.PullRequest.MergedBy.ID
+						if merger == 0 {
+							merger = entry.Actor.ID
+						}
+						interactions = append(interactions, prInteraction{key, merger, merge})
+					}
+				}
+			case "PullRequestReviewEvent":
+				interactions = append(interactions, prInteraction{key, entry.Actor.ID, review})
+			case "PullRequestReviewCommentEvent":
+				interactions = append(interactions, prInteraction{key, entry.Actor.ID, reviewComment})
+			}
+		}
+
+		graphs := PullRequestGraphs{Global: make(graph.Graph[uint32, PRInteraction])}
+		if scope == RepoScope {
+			graphs.PerRepo = make(map[uint32]graph.Graph[uint32, PRInteraction])
+		}
+		for _, interaction := range interactions {
+			author, ok := authors[interaction.pullRequest]
+			if !ok || author == interaction.user {
+				continue
+			}
+			addPRInteraction(graphs.Global, interaction.user, author, interaction.kind)
+			if graphs.PerRepo != nil {
+				repo := interaction.pullRequest.Repo
+				if graphs.PerRepo[repo] == nil {
+					graphs.PerRepo[repo] = make(graph.Graph[uint32, PRInteraction])
+				}
+				addPRInteraction(graphs.PerRepo[repo], interaction.user, author, interaction.kind)
+			}
+		}
+		return graphs
+	}
+}
+
+func addPRInteraction(g graph.Graph[uint32, PRInteraction], user, author uint32, kind prInteractionKind) {
+	if g[user] == nil {
+		g[user] = make(map[uint32]PRInteraction)
+	}
+	edge := g[user][author]
+	switch kind {
+	case review:
+		edge.Reviews++
+	case reviewComment:
+		edge.ReviewComments++
+	case merge:
+		edge.Merges++
+	}
+	g[user][author] = edge
+}
@@ -0,0 +1,74 @@
+package collabgraph
+
+/*
+Implements the temporal collab graph, recording when each user <-> repo interaction
+started and stopped, for churn and onboarding studies.
+*/
+
+import (
+	"sort"
+	"stream-parser/graph"
+	"time"
+)
+
+// Simillar to slimEvent, with the time of the event.
+type timedEvent struct {
+	CreatedAt string    `json:"created_at"`
+	Actor     slimActor `json:"actor"`
+	Repo      slimRepo  `json:"repo"`
+}
+
+const secondsPerDay = 24 * 60 * 60
+
+/*
+Takes in timed events, and generates the 2-partite graph graph[user][repo] = TemporalEdge.
+events arrive out of order (files are read in parallel), so the active days of an edge are
+kept as a sorted set until all events are read. most edges are active on a single day, which
+the edge itself tells (First and Last on the same day), so sets are kept only for edges active
+on several days. events with an unparsable created_at are dropped.
+*/
+func TemporalCollabGraphManeger(in <-chan timedEvent) graph.Graph[uint32, graph.TemporalEdge] {
+	result := make(graph.Graph[uint32, graph.TemporalEdge])
+	days := make(map[uint64][]int32) // the active days of edges active on more than a day
+	for entry := range in {
+		t, err := time.Parse(time.RFC3339, entry.CreatedAt)
+		if err != nil {
+			continue
+		}
+		seconds := t.Unix()
+		day := int32(seconds / secondsPerDay)
+
+		if result[entry.Actor.ID] == nil {
+			result[entry.Actor.ID] = make(map[uint32]graph.TemporalEdge)
+		}
+		edge, ok := result[entry.Actor.ID][entry.Repo.ID]
+		if !ok {
+			edge.First, edge.Last, edge.ActiveDays = seconds, seconds, 1
+		}
+		key := uint64(entry.Actor.ID)<<32 | uint64(entry.Repo.ID)
+		if edgeDay := int32(edge.First / secondsPerDay); edge.ActiveDays == 1 && day != edgeDay {
+			days[key] = insertDay([]int32{edgeDay}, day)
+			edge.ActiveDays = 2
+		} else if edge.ActiveDays > 1 {
+			days[key] = insertDay(days[key], day)
+			edge.ActiveDays = uint32(len(days[key]))
+		}
+		edge.First = min(edge.First, seconds)
+		edge.Last = max(edge.Last, seconds)
+		edge.Count++
+		result[entry.Actor.ID][entry.Repo.ID] = edge
+	}
+	return result
+}
+
+// Inserts a day into a sorted set of days.
+func insertDay(days []int32, day int32) []int32 {
+	i := sort.Search(len(days), func(i int) bool { return days[i] >= day })
+	if i < len(days) && days[i] == day {
+		return days
+	}
+	days = append(days, 0)
+	copy(days[i+1:], days[i:])
+	days[i] = day
+	return days
+}
@@ -0,0 +1,274 @@
+package collabgraph
+
+/*
+Implements collab graph managers aware of the event type, so a star (WatchEvent)
+is no longer counted as a merged pull request.
+*/
+
+import (
+	"fmt"
+	"strconv"
+	"stream-parser/graph"
+	"strings"
+)
+
+// Simillar to slimEvent, with the event type.
+type typedEvent struct {
+	Type  string    `json:"type"`
+	Actor slimActor `json:"actor"`
+	Repo  slimRepo  `json:"repo"`
+}
+
+// The GitHub event types, indexing EventCounts.
+type EventType uint8
+
+const (
+	EventCommitComment EventType = iota
+	EventCreate
+	EventDelete
+	EventFork
+	EventGollum
+	EventIssueComment
+	EventIssues
+	EventMember
+	EventPublic
+	EventPullRequest
+	EventPullRequestReview
+	EventPullRequestReviewComment
+	EventPullRequestReviewThread
+	EventPush
+	EventRelease
+	EventSponsorship
+	EventWatch
+	EventOther // any type not listed above
+	NumEventTypes
+)
+
+var eventTypeNames = [NumEventTypes]string{
+	"CommitCommentEvent",
+	"CreateEvent",
+	"DeleteEvent",
+	"ForkEvent",
+	"GollumEvent",
+	"IssueCommentEvent",
+	"IssuesEvent",
+	"MemberEvent",
+	"PublicEvent",
+	"PullRequestEvent",
+	"PullRequestReviewEvent",
+	"PullRequestReviewCommentEvent",
+	"PullRequestReviewThreadEvent",
+	"PushEvent",
+	"ReleaseEvent",
+	"SponsorshipEvent",
+	"WatchEvent",
+	"OtherEvent",
+}
+
+var eventTypesByName = func() map[string]EventType {
+	byName := make(map[string]EventType, NumEventTypes)
+	for i, name := range eventTypeNames {
+		byName[name] = EventType(i)
+	}
+	return byName
+}()
+
+// Returns the EventType of a GitHub event type name, EventOther for unknown names.
+func ParseEventType(name string) EventType {
+	if eventType, ok := eventTypesByName[name]; ok {
+		return eventType
+	}
+	return EventOther
+}
+
+func (t EventType) String() string {
+	if t >= NumEventTypes {
+		return eventTypeNames[EventOther]
+	}
+	return eventTypeNames[t]
+}
+
+/*
+The number of events of each type on a single user -> repo edge.
+
+Almost all edges have one or two event types, so the counts of two types are kept inline
+(24 bytes an edge, instead of 72 for a count per type), and an edge spills into a count per
+type once it sees a third type. copies of a spilled EventCounts share its counts.
+*/
+type EventCounts struct {
+	types  [2]EventType
+	counts [2]uint32 // 0 marks an unused slot
+	all    *[NumEventTypes]uint32
+}
+
+// The number of events of type t.
+func (c EventCounts) Get(t EventType) uint32 {
+	if c.all != nil {
+		return c.all[t]
+	}
+	for i := range c.counts {
+		if c.counts[i] != 0 && c.types[i] == t {
+			return c.counts[i]
+		}
+	}
+	return 0
+}
+
+// Adds n events of type t.
+func (c *EventCounts) Add(t EventType, n uint32) {
+	if n == 0 {
+		return
+	}
+	if c.all == nil {
+		for i := range c.counts {
+			if c.counts[i] != 0 && c.types[i] == t {
+				c.counts[i] += n
+				return
+			}
+		}
+		for i := range c.counts {
+			if c.counts[i] == 0 {
+				c.types[i], c.counts[i] = t, n
+				return
+			}
+		}
+		c.all = new([NumEventTypes]uint32)
+		for i := range c.counts {
+			c.all[c.types[i]] = c.counts[i]
+		}
+		c.types, c.counts = [2]EventType{}, [2]uint32{}
+	}
+	c.all[t] += n
+}
+
+// Gives the non zero counts to fn, ordered by type.
+func (c EventCounts) each(fn func(t EventType, count uint32)) {
+	if c.all != nil {
+		for i, count := range c.all {
+			if count != 0 {
+				fn(EventType(i), count)
+			}
+		}
+		return
+	}
+	first, second := 0, 1
+	if c.counts[second] != 0 && (c.counts[first] == 0 || c.types[second] < c.types[first]) {
+		first, second = second, first
+	}
+	for _, i := range [2]int{first, second} {
+		if c.counts[i] != 0 {
+			fn(c.types[i], c.counts[i])
+		}
+	}
+}
+
+func (c EventCounts) Total() uint32 {
+	var total uint32
+	c.each(func(_ EventType, count uint32) {
+		total += count
+	})
+	return total
+}
+
+// Implements graph.Relational, yielding the non zero counts by type.
+func (c EventCounts) Relations(yield func(relation string, weight uint32)) {
+	c.each(func(t EventType, count uint32) {
+		yield(t.String(), count)
+	})
+}
+
+/*
+Takes in typed events, and generates the multi relational 2-partite graph
+graph[user][repo] = counts of each event type.
+*/
+func TypedCollabGraphManeger(in <-chan typedEvent) graph.Graph[uint32, EventCounts] {
+	graph := make(graph.Graph[uint32, EventCounts])
+	for entry := range in {
+		if graph[entry.Actor.ID] == nil {
+			graph[entry.Actor.ID] = make(map[uint32]EventCounts)
+		}
+		counts := graph[entry.Actor.ID][entry.Repo.ID]
+		counts.Add(ParseEventType(entry.Type), 1)
+		graph[entry.Actor.ID][entry.Repo.ID] = counts
+	}
+	return graph
+}
+
+// The weight each event type adds to an edge. types with weight 0 do not create edges.
+type WeightTable [NumEventTypes]uint32
+
+// Weighs contributions above discussion, and discussion above stars.
+func DefaultWeightTable() WeightTable {
+	var table WeightTable
+	for i := range table {
+		table[i] = 1
+	}
+	table[EventPush] = 3
+	table[EventPullRequest] = 5
+	table[EventPullRequestReview] = 4
+	table[EventPullRequestReviewComment] = 2
+	table[EventIssues] = 2
+	table[EventIssueComment] = 2
+	table[EventRelease] = 3
+	return table
+}
+
+/*
+Parses a weight table of the form "PushEvent=3,PullRequestEvent=5,WatchEvent=1".
+types not listed get the weight of "*" if given, and 0 otherwise.
+*/
+func ParseWeightTable(spec string) (WeightTable, error) {
+	var table WeightTable
+	explicit := make(map[EventType]struct{})
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return table, fmt.Errorf("weight table entry %q is not of the form type=weight", entry)
+		}
+		weight, err := strconv.ParseUint(strings.TrimSpace(value), 10, 32)
+		if err != nil {
+			return table, fmt.Errorf("weight table entry %q: %w", entry, err)
+		}
+		name = strings.TrimSpace(name)
+		if name == "*" {
+			for i := range table {
+				if _, ok := explicit[EventType(i)]; !ok {
+					table[i] = uint32(weight)
+				}
+			}
+			continue
+		}
+		eventType, ok := eventTypesByName[name]
+		if !ok {
+			return table, fmt.Errorf("weight table entry %q: unknown event type", entry)
+		}
+		table[eventType] = uint32(weight)
+		explicit[eventType] = struct{}{}
+	}
+	return table, nil
+}
+
+/*
+A weighted version of the CollabGraphManeger, where each interaction adds the weight
+of its event type in the table, instead of 1 as in WeightedCollabGraphManeger.
+*/
+func TableWeightedCollabGraphManeger(table WeightTable) func(<-chan typedEvent) graph.Graph[uint32, uint32] {
+	return func(in <-chan typedEvent) graph.Graph[uint32, uint32] {
+		graph := make(graph.Graph[uint32, uint32])
+		for entry := range in {
+			weight := table[ParseEventType(entry.Type)]
+			if weight == 0 {
+				continue
+			}
+			if graph[entry.Actor.ID] == nil {
+				graph[entry.Actor.ID] = make(map[uint32]uint32)
+			}
+			graph[entry.Actor.ID][entry.Repo.ID] += weight
+		}
+		return graph
+	}
+}
@@ -0,0 +1,33 @@
+package collabgraph
+
+/*
+Implements time windowed snapshots of the weighted collab graph, for evolution studies
+such as how a contributor graph changed week over week.
+*/
+
+import (
+	"stream-parser/graph"
+	"time"
+)
+
+/*
+A weighted collab graph per window, see WeightedCollabGraphManeger.
+events with an unparsable created_at are dropped.
+*/
+func WindowedCollabGraphManeger(window graph.Window) func(<-chan timedEvent) graph.Snapshots[uint32, uint32] {
+	return func(in <-chan timedEvent) graph.Snapshots[uint32, uint32] {
+		snapshots := graph.NewSnapshots[uint32, uint32](window)
+		for entry := range in {
+			t, err := time.Parse(time.RFC3339, entry.CreatedAt)
+			if err != nil {
+				continue
+			}
+			graph := snapshots.At(t)
+			if graph[entry.Actor.ID] == nil {
+				graph[entry.Actor.ID] = make(map[uint32]uint32)
+			}
+			graph[entry.Actor.ID][entry.Repo.ID] += 1
+		}
+		return snapshots
+	}
+}
@@ -0,0 +1,201 @@
+/*
+Implements a dictionary of actor and repo names.
+
+The graphs only hold the numeric ids, this maps them back to Actor.Login and Repo.Name,
+keeping every name an id was seen with (renames) and when it was first and last seen.
+*/
+package dictionary
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"time"
+)
+
+/*
+A name an id was seen with, and the unix seconds it was first and last seen.
+a name used again after a rename gets a new record, so records never overlap.
+*/
+type Name struct {
+	Name      string
+	FirstSeen int64
+	LastSeen  int64
+}
+
+// The names of each id, ordered by FirstSeen.
+type Names map[uint32][]Name
+
+type Dictionary struct {
+	Actors Names
+	Repos  Names
+}
+
+// Simillar to BaseEvent, slimmed to the names and time.
+type NamedEvent struct {
+	CreatedAt string     `json:"created_at"`
+	Actor     NamedActor `json:"actor"`
+	Repo      NamedRepo  `json:"repo"`
+}
+
+type NamedActor struct {
+	ID    uint32 `json:"id"`
+	Login string `json:"login"`
+}
+
+type NamedRepo struct {
+	ID   uint32 `json:"id"`
+	Name string `json:"name"`
+}
+
+func New() *Dictionary {
+	return &Dictionary{Actors: make(Names), Repos: make(Names)}
+}
+
+/*
+Collects the actor logins and repo names of all events into a dictionary.
+events with an unparsable created_at are still recorded, with a zero time.
+*/
+func DictionaryManeger(in <-chan NamedEvent) *Dictionary {
+	dict := New()
+	for entry := range in {
+		var seen int64
+		if t, err := time.Parse(time.RFC3339, entry.CreatedAt); err == nil {
+			seen = t.Unix()
+		}
+		dict.Actors.Add(entry.Actor.ID, entry.Actor.Login, seen)
+		dict.Repos.Add(entry.Repo.ID, entry.Repo.Name, seen)
+	}
+	return dict
+}
+
+// Records that id was seen with name at the given unix seconds. empty names are ignored.
+func (n Names) Add(id uint32, name string, seen int64) {
+	n.add(id, Name{Name: name, FirstSeen: seen, LastSeen: seen})
+}
+
+// Records the sightings of record, at its first and last seen.
+func (n Names) add(id uint32, record Name) {
+	if record.Name == "" {
+		return
+	}
+	n.see(id, record.Name, record.FirstSeen)
+	n.see(id, record.Name, record.LastSeen)
+}
+
+/*
+Records a single sighting. it extends the record of the same name next to it in time,
+or starts a new one. a sighting inside the record of another name splits that record
+around it, keeping its first and last seen, as the sightings in between are not known.
+*/
+func (n Names) see(id uint32, name string, seen int64) {
+	names := n[id]
+	// the first record starting after seen, the one before it is the record seen falls in
+	i := sort.Search(len(names), func(i int) bool {
+		return names[i].FirstSeen > seen
+	})
+	if i > 0 {
+		previous := &names[i-1]
+		if previous.Name == name {
+			previous.LastSeen = max(previous.LastSeen, seen)
+			return
+		}
+		if previous.FirstSeen < seen && seen < previous.LastSeen {
+			after := Name{Name: previous.Name, FirstSeen: previous.LastSeen, LastSeen: previous.LastSeen}
+			previous.LastSeen = previous.FirstSeen
+			n[id] = slices.Insert(names, i, Name{Name: name, FirstSeen: seen, LastSeen: seen}, after)
+			return
+		}
+	}
+	if i < len(names) && names[i].Name == name {
+		names[i].FirstSeen = seen
+		return
+	}
+	n[id] = slices.Insert(names, i, Name{Name: name, FirstSeen: seen, LastSeen: seen})
+}
+
+func sortNames(names []Name) {
+	sort.SliceStable(names, func(i, j int) bool {
+		return names[i].FirstSeen < names[j].FirstSeen
+	})
+}
+
+// Merges other into n, for example dictionaries of diffrent periods.
+func (n Names) Merge(other Names) {
+	for id, names := range other {
+		for _, record := range names {
+			n.add(id, record)
+		}
+	}
+}
+
+// The latest name of id.
+func (n Names) Latest(id uint32) (string, bool) {
+	names := n[id]
+	if len(names) == 0 {
+		return "", false
+	}
+	latest := names[0]
+	for _, record := range names[1:] {
+		if record.LastSeen >= latest.LastSeen {
+			latest = record
+		}
+	}
+	return latest.Name, true
+}
+
+/*
+The name id had at the given time, that is the last name first seen before it.
+times before the first sighting return the first name.
+*/
+func (n Names) At(id uint32, at time.Time) (string, bool) {
+	names := n[id]
+	if len(names) == 0 {
+		return "", false
+	}
+	seconds := at.Unix()
+	i := sort.Search(len(names), func(i int) bool {
+		return names[i].FirstSeen > seconds
+	})
+	if i == 0 {
+		return names[0].Name, true
+	}
+	return names[i-1].Name, true
+}
+
+// The latest name of id, or the id itself if it is unknown. can be used as a graph.Labeler.
+func (n Names) Label(id uint32) string {
+	if name, ok := n.Latest(id); ok {
+		return name
+	}
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func (d *Dictionary) Merge(other *Dictionary) {
+	d.Actors.Merge(other.Actors)
+	d.Repos.Merge(other.Repos)
+}
+
+func (d *Dictionary) ActorLogin(id uint32) (string, bool) {
+	return d.Actors.Latest(id)
+}
+
+func (d *Dictionary) RepoName(id uint32) (string, bool) {
+	return d.Repos.Latest(id)
+}
+
+func (d *Dictionary) ActorLoginAt(id uint32, at time.Time) (string, bool) {
+	return d.Actors.At(id, at)
+}
+
+func (d *Dictionary) RepoNameAt(id uint32, at time.Time) (string, bool) {
+	return d.Repos.At(id, at)
+}
+
+func (d *Dictionary) ActorLabel(id uint32) string {
+	return d.Actors.Label(id)
+}
+
+func (d *Dictionary) RepoLabel(id uint32) string {
+	return d.Repos.Label(id)
+}
@@ -0,0 +1,193 @@
+package dictionary
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDictionaryRoundTrip(t *testing.T) {
+	in := make(chan NamedEvent, 4)
+	in <- NamedEvent{CreatedAt: "2025-03-01T00:00:00Z", Actor: NamedActor{1, "alice"}, Repo: NamedRepo{10, "alice/old"}}
+	in <- NamedEvent{CreatedAt: "2025-01-01T00:00:00Z", Actor: NamedActor{1, "alice"}, Repo: NamedRepo{10, "alice/old"}}
+	in <- NamedEvent{CreatedAt: "2025-06-01T00:00:00Z", Actor: NamedActor{2, "bob"}, Repo: NamedRepo{10, "org/new"}}
+	in <- NamedEvent{CreatedAt: "2025-02-01T00:00:00Z", Actor: NamedActor{2, "bob"}, Repo: NamedRepo{11, "alice/old"}}
+	close(in)
+	dict := DictionaryManeger(in)
+
+	if name, _ := dict.RepoName(10); name != "org/new" {
+		t.Errorf("expected latest name org/new, got %v", name)
+	}
+	if name, _ := dict.RepoNameAt(10, time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)); name != "alice/old" {
+		t.Errorf("expected alice/old in april, got %v", name)
+	}
+	if label := dict.ActorLabel(3); label != "3" {
+		t.Errorf("expected unknown actor label to be its id, got %v", label)
+	}
+	old := dict.Repos[10][0]
+	if old.FirstSeen != time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC).Unix() ||
+		old.LastSeen != time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC).Unix() {
+		t.Errorf("unexpected first/last seen %+v", old)
+	}
+
+	filename := filepath.Join(t.TempDir(), "dict.bin")
+	if err := Write(filename, dict); err != nil {
+		t.Fatal(err)
+	}
+	read, err := Read(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dict, read) {
+		t.Errorf("round trip mismatch:\n%+v\n%+v", dict, read)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteTo(&buf, dict); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := ReadFrom(&buf); err != nil || !reflect.DeepEqual(dict, read) {
+		t.Errorf("reader round trip mismatch %v", err)
+	}
+
+	// dictionaries are compressed by extension, and read back whatever their name
+	compressed := filepath.Join(t.TempDir(), "dict.bin.zst")
+	if err := Write(compressed, dict); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(compressed); bytes.HasPrefix(data, []byte(magic)) {
+		t.Errorf("expected a compressed dictionary")
+	}
+	if read, err := Read(compressed); err != nil || !reflect.DeepEqual(dict, read) {
+		t.Errorf("compressed round trip mismatch %v", err)
+	}
+}
+
+func TestRenameBack(t *testing.T) {
+	day := func(d int) int64 { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC).Unix() }
+	names := make(Names)
+	names.Add(10, "a", day(1))
+	names.Add(10, "a", day(3))
+	names.Add(10, "b", day(5))
+	names.Add(10, "a", day(20)) // renamed back
+	names.Add(10, "b", day(8))  // out of order
+	names.Add(10, "a", day(25))
+
+	at := func(d int) string {
+		name, _ := names.At(10, time.Unix(day(d), 0))
+		return name
+	}
+	if at(2) != "a" || at(6) != "b" || at(21) != "a" || at(30) != "a" {
+		t.Errorf("unexpected names by time %v %v %v %v", at(2), at(6), at(21), at(30))
+	}
+	if len(names[10]) != 3 || names[10][1] != (Name{"b", day(5), day(8)}) || names[10][2] != (Name{"a", day(20), day(25)}) {
+		t.Errorf("unexpected records %+v", names[10])
+	}
+
+	// a sighting inside a record of another name splits it
+	names.Add(10, "c", day(22))
+	if at(23) != "c" || at(25) != "a" || len(names[10]) != 5 {
+		t.Errorf("unexpected records after split %+v", names[10])
+	}
+}
+
+func TestReadCorruptDictionary(t *testing.T) {
+	var huge [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(huge[:], math.MaxUint64)
+	maxID := binary.AppendUvarint(nil, math.MaxUint32)
+	for _, body := range [][]byte{
+		append([]byte{1, 1}, huge[:n]...),               // an id with a huge name count
+		append([]byte{1, 1, 1}, huge[:n]...),            // a name with a huge length
+		append(append([]byte{1, 1, 1}, 100), "ab"...),   // a name longer than the input
+		append(append([]byte{2}, maxID...), 0, 1, 0, 0), // an id delta overflowing uint32
+		{2, 5, 0, 0, 0}, // a duplicate id
+		{1, 1, 2, 1, 'a', 20, 0, 1, 'b', 10, 0, 0},   // names not sorted by first seen
+		append(append([]byte{1}, huge[:n]...), 0, 0), // a huge id delta
+	} {
+		filename := filepath.Join(t.TempDir(), "dict.bin")
+		if err := os.WriteFile(filename, append([]byte{'G', 'H', 'D', 'I', 'C', 'T', version}, body...), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Read(filename); err == nil {
+			t.Errorf("expected an error reading %v", body)
+		}
+	}
+}
+
+func TestResolver(t *testing.T) {
+	day := func(d int) int64 { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC).Unix() }
+	repos := make(Names)
+	repos.Add(10, "alice/tool", day(1))
+	repos.Add(10, "org/tool", day(10))   // transferred
+	repos.Add(11, "alice/tool", day(20)) // name reused after the transfer
+	resolver := NewResolver(repos)
+
+	if id, _ := resolver.ResolveName("alice/tool", time.Unix(day(5), 0)); id != 10 {
+		t.Errorf("expected alice/tool to be 10 before the transfer, got %d", id)
+	}
+	if id, _ := resolver.ResolveName("alice/tool", time.Unix(day(25), 0)); id != 11 {
+		t.Errorf("expected alice/tool to be 11 after recreation, got %d", id)
+	}
+	if label := resolver.Label(10); label != "org/tool" {
+		t.Errorf("unexpected label %v", label)
+	}
+	if label := resolver.Label(11); label != "alice/tool#11" {
+		t.Errorf("unexpected label %v", label)
+	}
+	if reused := resolver.Reused()["alice/tool"]; !reflect.DeepEqual(reused, []uint32{10, 11}) {
+		t.Errorf("unexpected reused ids %v", reused)
+	}
+
+	if err := resolver.Link(11, 10); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := resolver.Resolve(11, "", time.Time{}); id != 10 {
+		t.Errorf("expected linked id to resolve to 10, got %d", id)
+	}
+	if identity, _ := resolver.Identity(11); len(identity.Aliases) != 3 || identity.Name != "alice/tool" {
+		t.Errorf("unexpected linked identity %+v", identity)
+	}
+
+	// relinking would leave the aliases merged into the first identity
+	repos.Add(12, "bob/tool", day(2))
+	resolver = NewResolver(repos)
+	resolver.Link(11, 10)
+	if err := resolver.Link(11, 12); err == nil || resolver.Canonical(11) != 10 {
+		t.Errorf("expected an error relinking 11, got %v", err)
+	}
+	if err := resolver.Link(11, 10); err != nil {
+		t.Errorf("linking to the same identity again should be a no op, got %v", err)
+	}
+	if err := resolver.Link(10, 11); err != nil || resolver.Canonical(10) != 10 {
+		t.Errorf("links that would make a cycle should be ignored, got %v", err)
+	}
+}
+
+func TestReadLinks(t *testing.T) {
+	day := func(d int) int64 { return time.Date(2025, 1, d, 0, 0, 0, 0, time.UTC).Unix() }
+	repos := make(Names)
+	repos.Add(10, "alice/tool", day(1))
+	repos.Add(11, "alice/tool", day(20))
+	repos.Add(12, "alice/fork", day(1))
+	resolver := NewResolver(repos)
+
+	if err := resolver.ReadLinksFrom(strings.NewReader("# links\n\nalice/tool\n12 10\n")); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []uint32{10, 11, 12} {
+		if canonical := resolver.Canonical(id); canonical != 11 {
+			t.Errorf("expected %d to resolve to 11, got %d", id, canonical)
+		}
+	}
+	for _, links := range []string{"unknown/repo\n", "1 2 3\n", "x 1\n", "13 10\n13 14\n"} {
+		if err := resolver.ReadLinksFrom(strings.NewReader(links)); err == nil {
+			t.Errorf("expected an error reading %q", links)
+		}
+	}
+}
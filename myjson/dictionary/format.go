@@ -0,0 +1,201 @@
+package dictionary
+
+/*
+Implements the on disk format of the dictionary.
+
+	magic "GHDICT" | version | actors section | repos section
+
+where each section is
+
+	id count | (id delta | name count | (name length | name | first seen | last seen - first seen) ...) ...
+
+all numbers are varints, ids are sorted and written as the delta from the previous id.
+*/
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"stream-parser/graph"
+	"strings"
+)
+
+const (
+	magic   = "GHDICT"
+	version = 1
+)
+
+// Writes a dictionary through a sink, compressed by the extension of filename (see graph.CreateSink).
+func Write(filename string, dict *Dictionary) error {
+	sink, err := graph.CreateSink(filename)
+	if err != nil {
+		return err
+	}
+	if err := WriteTo(sink.Writer, dict); err != nil {
+		sink.Close()
+		return fmt.Errorf("%v: %w", filename, err)
+	}
+	return sink.Close()
+}
+
+// Writes a dictionary in the format of Write.
+func WriteTo(w io.Writer, dict *Dictionary) error {
+	writer := graph.BufferedWriter(w)
+	if _, err := writer.WriteString(magic); err != nil {
+		return err
+	}
+	if err := writer.WriteByte(version); err != nil {
+		return err
+	}
+	if err := writeNames(writer, dict.Actors); err != nil {
+		return err
+	}
+	if err := writeNames(writer, dict.Repos); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func writeNames(writer *bufio.Writer, names Names) error {
+	ids := make([]uint32, 0, len(names))
+	for id := range names {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	buf := make([]byte, binary.MaxVarintLen64)
+	putUvarint := func(v uint64) error {
+		_, err := writer.Write(buf[:binary.PutUvarint(buf, v)])
+		return err
+	}
+	putVarint := func(v int64) error {
+		_, err := writer.Write(buf[:binary.PutVarint(buf, v)])
+		return err
+	}
+
+	if err := putUvarint(uint64(len(ids))); err != nil {
+		return err
+	}
+	var previous uint32
+	for _, id := range ids {
+		if err := putUvarint(uint64(id - previous)); err != nil {
+			return err
+		}
+		previous = id
+		if err := putUvarint(uint64(len(names[id]))); err != nil {
+			return err
+		}
+		for _, record := range names[id] {
+			if err := putUvarint(uint64(len(record.Name))); err != nil {
+				return err
+			}
+			if _, err := writer.WriteString(record.Name); err != nil {
+				return err
+			}
+			if err := putVarint(record.FirstSeen); err != nil {
+				return err
+			}
+			if err := putUvarint(uint64(record.LastSeen - record.FirstSeen)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Reads a dictionary written by Write, decompressing it when compressed.
+func Read(filename string) (*Dictionary, error) {
+	file, err := graph.OpenSource(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	dict, err := ReadFrom(file)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", filename, err)
+	}
+	return dict, nil
+}
+
+// Reads a dictionary in the format of Write.
+func ReadFrom(r io.Reader) (*Dictionary, error) {
+	reader := graph.BufferedReader(r)
+	header := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, fmt.Errorf("not a dictionary file")
+	}
+	if header[len(magic)] != version {
+		return nil, fmt.Errorf("unsupported dictionary version %d", header[len(magic)])
+	}
+
+	dict := New()
+	if err := readNames(reader, dict.Actors); err != nil {
+		return nil, fmt.Errorf("reading actors: %w", err)
+	}
+	if err := readNames(reader, dict.Repos); err != nil {
+		return nil, fmt.Errorf("reading repos: %w", err)
+	}
+	return dict, nil
+}
+
+func readNames(reader *bufio.Reader, names Names) error {
+	count, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return err
+	}
+	var id uint32
+	for i := uint64(0); i < count; i++ {
+		delta, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return err
+		}
+		if delta > math.MaxUint32-uint64(id) {
+			return fmt.Errorf("id delta %d after id %d overflows uint32", delta, id)
+		}
+		if delta == 0 && i > 0 {
+			return fmt.Errorf("duplicate id %d", id)
+		}
+		id += uint32(delta)
+
+		nameCount, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return err
+		}
+		// the counts and lengths are not trusted, memory only grows with the input actually read
+		records := make([]Name, 0, min(nameCount, 8))
+		for j := uint64(0); j < nameCount; j++ {
+			length, err := binary.ReadUvarint(reader)
+			if err != nil {
+				return err
+			}
+			var name strings.Builder
+			if _, err := io.CopyN(&name, reader, int64(min(length, math.MaxInt64))); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return err
+			}
+			first, err := binary.ReadVarint(reader)
+			if err != nil {
+				return err
+			}
+			span, err := binary.ReadUvarint(reader)
+			if err != nil {
+				return err
+			}
+			// see and At search the names of an id by FirstSeen
+			if len(records) > 0 && first < records[len(records)-1].FirstSeen {
+				return fmt.Errorf("names of id %d are not sorted by first seen", id)
+			}
+			records = append(records, Name{Name: name.String(), FirstSeen: first, LastSeen: first + int64(span)})
+		}
+		names[id] = records
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package dictionary
+
+/*
+Implements resolution of repository identities.
+
+GH Archive records the repo name as it was at the time of the event, so:
+	- A renamed or transferred repo appears under several names, with one id.
+	- A deleted and recreated repo (or a name freed by a rename) reuses a name, with a diffrent id.
+
+The Resolver builds from the repo names of a Dictionary the identity of each repo
+(its id, latest name and alias history), and the history of each name, so that
+both names at a point in time and ids can be resolved to a canonical identity.
+*/
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The identity of a single repository across its renames and transfers.
+type RepoIdentity struct {
+	ID      uint32 // the canonical id
+	Name    string // the latest name
+	Aliases []Name // all the names of the repo ordered by FirstSeen, including Name
+}
+
+// A period in which a name belonged to a repo id.
+type nameClaim struct {
+	ID uint32
+	Name
+}
+
+type Resolver struct {
+	identities map[uint32]*RepoIdentity
+	claims     map[string][]nameClaim // name -> claims ordered by FirstSeen
+	links      map[uint32]uint32      // explicitly linked ids, see Link
+}
+
+func NewResolver(repos Names) *Resolver {
+	r := &Resolver{
+		identities: make(map[uint32]*RepoIdentity, len(repos)),
+		claims:     make(map[string][]nameClaim),
+		links:      make(map[uint32]uint32),
+	}
+	for id, names := range repos {
+		latest, _ := repos.Latest(id)
+		r.identities[id] = &RepoIdentity{ID: id, Name: latest, Aliases: names}
+		for _, record := range names {
+			r.claims[record.Name] = append(r.claims[record.Name], nameClaim{ID: id, Name: record})
+		}
+	}
+	for _, claims := range r.claims {
+		sort.Slice(claims, func(i, j int) bool {
+			if claims[i].FirstSeen != claims[j].FirstSeen {
+				return claims[i].FirstSeen < claims[j].FirstSeen
+			}
+			return claims[i].ID < claims[j].ID
+		})
+	}
+	return r
+}
+
+/*
+Declares that the repo from is the same project as the repo to, for example
+when a repo was deleted and recreated by its owner. from is then resolved to
+the canonical identity of to. a repo can be linked once: linking it again to
+another identity is an error, as its aliases were merged into the first.
+*/
+func (r *Resolver) Link(from, to uint32) error {
+	if r.Canonical(to) == r.Canonical(from) {
+		return nil // linked already, or a cycle
+	}
+	if linked, ok := r.links[from]; ok {
+		return fmt.Errorf("repo %d is linked to %d already, can't link it to %d", from, r.Canonical(linked), to)
+	}
+	r.links[from] = to
+
+	source, okSource := r.identities[from]
+	target, okTarget := r.identities[r.Canonical(to)]
+	if okSource && okTarget {
+		aliases := append(append([]Name{}, target.Aliases...), source.Aliases...)
+		sortNames(aliases)
+		target.Aliases = aliases
+		latest := aliases[0]
+		for _, record := range aliases[1:] {
+			if record.LastSeen >= latest.LastSeen {
+				latest = record
+			}
+		}
+		target.Name = latest.Name
+	}
+	return nil
+}
+
+/*
+Links all the identities that held name to the one holding it last, for example a repo
+deleted and recreated under the same name. returns the canonical id they were linked to.
+*/
+func (r *Resolver) LinkName(name string) (uint32, bool) {
+	claims := r.claims[name]
+	if len(claims) == 0 {
+		return 0, false
+	}
+	to := r.Canonical(claims[len(claims)-1].ID)
+	for _, claim := range claims {
+		// the canonical ids are linked, as the ids may be linked already
+		r.Link(r.Canonical(claim.ID), to)
+	}
+	return to, true
+}
+
+/*
+Reads links into the resolver, a link per line, either "from to" linking the repo id from
+to the repo id to (see Link), or a single repo name linking all the ids that held it (see LinkName).
+empty lines and lines starting with # are ignored.
+*/
+func (r *Resolver) ReadLinks(filename string) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := r.ReadLinksFrom(file); err != nil {
+		return fmt.Errorf("%v: %w", filename, err)
+	}
+	return nil
+}
+
+// Reads links in the format of ReadLinks.
+func (r *Resolver) ReadLinksFrom(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		tokens := strings.Fields(line)
+		switch len(tokens) {
+		case 1:
+			if _, ok := r.LinkName(tokens[0]); !ok {
+				return fmt.Errorf("line %d: unknown repo name %q", lineNumber, tokens[0])
+			}
+		case 2:
+			from, err := strconv.ParseUint(tokens[0], 10, 32)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			to, err := strconv.ParseUint(tokens[1], 10, 32)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			if err := r.Link(uint32(from), uint32(to)); err != nil {
+				return fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+		default:
+			return fmt.Errorf("line %d: expected \"from to\" or a repo name, got %q", lineNumber, line)
+		}
+	}
+	return scanner.Err()
+}
+
+// The canonical id of a repo id, following links.
+func (r *Resolver) Canonical(id uint32) uint32 {
+	for {
+		to, ok := r.links[id]
+		if !ok {
+			return id
+		}
+		id = to
+	}
+}
+
+func (r *Resolver) Identity(id uint32) (*RepoIdentity, bool) {
+	identity, ok := r.identities[r.Canonical(id)]
+	return identity, ok
+}
+
+/*
+The canonical id of the repo that held name at the given time. the claim with
+the latest FirstSeen before the time wins, times before any claim resolve to the first one.
+*/
+func (r *Resolver) ResolveName(name string, at time.Time) (uint32, bool) {
+	claims := r.claims[name]
+	if len(claims) == 0 {
+		return 0, false
+	}
+	seconds := at.Unix()
+	i := sort.Search(len(claims), func(i int) bool {
+		return claims[i].FirstSeen > seconds
+	})
+	if i > 0 {
+		i--
+	}
+	return r.Canonical(claims[i].ID), true
+}
+
+/*
+Resolves an event's repo to its canonical id. events hold an id almost always,
+the name and time are used only when the id is missing (0).
+*/
+func (r *Resolver) Resolve(id uint32, name string, at time.Time) (uint32, bool) {
+	if id != 0 {
+		return r.Canonical(id), true
+	}
+	return r.ResolveName(name, at)
+}
+
+// Names that belonged to more than one identity, and the canonical ids holding them by FirstSeen.
+func (r *Resolver) Reused() map[string][]uint32 {
+	reused := make(map[string][]uint32)
+	for name, claims := range r.claims {
+		ids := make([]uint32, 0, len(claims))
+		seen := make(map[uint32]struct{}, len(claims))
+		for _, claim := range claims {
+			id := r.Canonical(claim.ID)
+			if _, ok := seen[id]; !ok {
+				seen[id] = struct{}{}
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) > 1 {
+			reused[name] = ids
+		}
+	}
+	return reused
+}
+
+/*
+The latest name of the repo's identity, suffixed by "#id" when the name
+belonged to other identities as well, so labels stay unique. can be used as a graph.Labeler.
+*/
+func (r *Resolver) Label(id uint32) string {
+	identity, ok := r.Identity(id)
+	if !ok || identity.Name == "" {
+		return strconv.FormatUint(uint64(id), 10)
+	}
+	for _, claim := range r.claims[identity.Name] {
+		if r.Canonical(claim.ID) != identity.ID {
+			return identity.Name + "#" + strconv.FormatUint(uint64(identity.ID), 10)
+		}
+	}
+	return identity.Name
+}
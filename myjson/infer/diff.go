@@ -0,0 +1,136 @@
+package infer
+
+/*
+Implements schema drift detection between two periods of the archive.
+
+GitHub changes payloads silently (for example the 2025 PushEvent slimming,
+that dropped the commits list), so comparing the merged schema of a known good
+period to a new one catches these changes before the managers break on them.
+*/
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type ChangeKind string
+
+const (
+	FieldAdded      ChangeKind = "added"
+	FieldRemoved    ChangeKind = "removed"
+	TypeChanged     ChangeKind = "type-changed"
+	PresenceShifted ChangeKind = "presence-shifted"
+)
+
+// A single difference between two schemas. an empty Path means the whole event type.
+type Change struct {
+	EventType   string
+	Path        string
+	Kind        ChangeKind
+	OldTypes    []string
+	NewTypes    []string
+	OldPresence float64
+	NewPresence float64
+}
+
+func (c Change) String() string {
+	name := c.EventType
+	if c.Path != "" {
+		name += " " + c.Path
+	}
+	switch c.Kind {
+	case TypeChanged:
+		return fmt.Sprintf("%s %s: [%s] -> [%s]", c.Kind, name,
+			strings.Join(c.OldTypes, ","), strings.Join(c.NewTypes, ","))
+	default:
+		return fmt.Sprintf("%s %s: presence %.4f -> %.4f", c.Kind, name, c.OldPresence, c.NewPresence)
+	}
+}
+
+/*
+Compares the schema of two periods of the same event type.
+
+Parameters:
+  - before, after	The schemas to compare, usually before is the earlier period.
+  - threshold		The minimal absolute change in presence rate to be reported as PresenceShifted,
+			unchanged rates are never reported.
+*/
+func DiffSchema(before, after *Schema, threshold float64) []Change {
+	paths := make(map[string]struct{}, len(before.Fields)+len(after.Fields))
+	for path := range before.Fields {
+		paths[path] = struct{}{}
+	}
+	for path := range after.Fields {
+		paths[path] = struct{}{}
+	}
+
+	changes := make([]Change, 0)
+	for path := range paths {
+		change := Change{
+			Path:        path,
+			OldTypes:    before.Types(path),
+			NewTypes:    after.Types(path),
+			OldPresence: before.Presence(path),
+			NewPresence: after.Presence(path),
+		}
+		_, inOld := before.Fields[path]
+		_, inNew := after.Fields[path]
+		switch {
+		case !inOld:
+			change.Kind = FieldAdded
+		case !inNew:
+			change.Kind = FieldRemoved
+		case !slices.Equal(change.OldTypes, change.NewTypes):
+			change.Kind = TypeChanged
+		case change.NewPresence != change.OldPresence && math.Abs(change.NewPresence-change.OldPresence) >= threshold:
+			change.Kind = PresenceShifted
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+/*
+Compares two sets of schemas, see DiffSchema.
+Event types that appear only in one of them are reported once with an empty path.
+*/
+func DiffSchemas(before, after Schemas, threshold float64) []Change {
+	eventTypes := make(map[string]struct{}, len(before)+len(after))
+	for eventType := range before {
+		eventTypes[eventType] = struct{}{}
+	}
+	for eventType := range after {
+		eventTypes[eventType] = struct{}{}
+	}
+	sorted := make([]string, 0, len(eventTypes))
+	for eventType := range eventTypes {
+		sorted = append(sorted, eventType)
+	}
+	sort.Strings(sorted)
+
+	changes := make([]Change, 0)
+	for _, eventType := range sorted {
+		oldSchema, inOld := before[eventType]
+		newSchema, inNew := after[eventType]
+		switch {
+		case !inOld:
+			changes = append(changes, Change{EventType: eventType, Kind: FieldAdded, NewPresence: 1})
+		case !inNew:
+			changes = append(changes, Change{EventType: eventType, Kind: FieldRemoved, OldPresence: 1})
+		default:
+			for _, change := range DiffSchema(oldSchema, newSchema, threshold) {
+				change.EventType = eventType
+				changes = append(changes, change)
+			}
+		}
+	}
+	return changes
+}
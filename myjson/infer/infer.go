@@ -6,8 +6,12 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+
+	jsoniter "github.com/json-iterator/go"
 )
 
+var json = jsoniter.ConfigCompatibleWithStandardLibrary
+
 /*
 This is very costly. because although they are not many diffrent core types,
 they ARE diffrent. there are many more close types that are counted as "diffrent".
@@ -26,7 +30,7 @@ func InferManeger(in <-chan string) {
 		defer file.Close()
 
 		// Write string to file
-		_, err = fmt.Fprintf(file, fmt.Sprintf("%v\n", entry))
+		_, err = fmt.Fprintf(file, "%v\n", entry)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error encountered %v\n", err);
 		}	
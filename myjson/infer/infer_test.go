@@ -0,0 +1,92 @@
+package infer
+
+import (
+	"slices"
+	"testing"
+)
+
+func samples(t *testing.T, lines ...string) Schemas {
+	in := make(chan Sample, len(lines))
+	for _, line := range lines {
+		var sample Sample
+		if err := json.Unmarshal([]byte(line), &sample); err != nil {
+			t.Fatal(err)
+		}
+		in <- sample
+	}
+	close(in)
+	return SchemaManeger(in)
+}
+
+func TestSchemaManeger(t *testing.T) {
+	schemas := samples(t,
+		`{"type":"PushEvent","payload":{"size":1,"commits":[{"sha":"a"},{"sha":"b"}]}}`,
+		`{"type":"PushEvent","payload":{"size":null}}`,
+	)
+	push := schemas["PushEvent"]
+	if push.Samples != 2 {
+		t.Fatalf("expected 2 samples, got %d", push.Samples)
+	}
+	if got := push.Presence("payload.commits[].sha"); got != 0.5 {
+		t.Errorf("expected commits sha presence 0.5, got %v", got)
+	}
+	if got := push.Types("payload.size"); !slices.Equal(got, []string{"null", "number"}) {
+		t.Errorf("unexpected size types %v", got)
+	}
+
+	doc := push.JSONSchema()
+	payload := doc["properties"].(map[string]any)["payload"].(map[string]any)
+	if required := payload["required"].([]string); !slices.Equal(required, []string{"size"}) {
+		t.Errorf("unexpected required %v", required)
+	}
+	size := payload["properties"].(map[string]any)["size"].(map[string]any)
+	if types := size["type"].([]string); !slices.Equal(types, []string{"null", "number"}) {
+		t.Errorf("unexpected size json types %v", types)
+	}
+}
+
+func TestDiffSchemas(t *testing.T) {
+	january := samples(t,
+		`{"type":"PushEvent","payload":{"size":1,"commits":[{"sha":"a"}]}}`,
+		`{"type":"WatchEvent","payload":{"action":"started"}}`,
+	)
+	june := samples(t,
+		`{"type":"PushEvent","payload":{"size":"1","head":"a"}}`,
+		`{"type":"ForkEvent","payload":{}}`,
+	)
+
+	got := make(map[string]ChangeKind)
+	for _, change := range DiffSchemas(january, june, 0.1) {
+		got[change.EventType+" "+change.Path] = change.Kind
+	}
+	expected := map[string]ChangeKind{
+		"ForkEvent ":                      FieldAdded,
+		"WatchEvent ":                     FieldRemoved,
+		"PushEvent payload.head":          FieldAdded,
+		"PushEvent payload.commits":       FieldRemoved,
+		"PushEvent payload.commits[]":     FieldRemoved,
+		"PushEvent payload.commits[].sha": FieldRemoved,
+		"PushEvent payload.size":          TypeChanged,
+	}
+	if len(got) != len(expected) {
+		t.Errorf("expected %d changes, got %v", len(expected), got)
+	}
+	for key, kind := range expected {
+		if got[key] != kind {
+			t.Errorf("%s: expected %s, got %s", key, kind, got[key])
+		}
+	}
+
+	// with no threshold, only paths whose presence changed are reported
+	for _, change := range DiffSchemas(january, january, 0) {
+		t.Errorf("unexpected change of identical schemas %v", change)
+	}
+	shifted := samples(t,
+		`{"type":"WatchEvent","payload":{"action":"started"}}`,
+		`{"type":"WatchEvent","payload":{}}`,
+	)
+	changes := DiffSchemas(Schemas{"WatchEvent": january["WatchEvent"]}, shifted, 0)
+	if len(changes) != 1 || changes[0].Path != "payload.action" || changes[0].Kind != PresenceShifted {
+		t.Errorf("expected a single presence shift, got %v", changes)
+	}
+}
@@ -0,0 +1,159 @@
+package infer
+
+/*
+Exports merged schemas as JSON Schema (draft 2020-12).
+
+Besides the standard keywords each node carries the annotations
+"x-presence" (rate of samples the path was present in) and "x-samples",
+so the exported document keeps what the diff is based on.
+*/
+
+import (
+	"sort"
+	"strings"
+)
+
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// The type names used by typeOf, as named by JSON Schema.
+var jsonSchemaTypes = map[string]string{
+	"string": "string",
+	"number": "number",
+	"bool":   "boolean",
+	"null":   "null",
+	"object": "object",
+	"array":  "array",
+}
+
+type schemaNode struct {
+	path       string
+	properties map[string]*schemaNode
+	items      *schemaNode
+}
+
+/*
+Returns the JSON Schema of a single event type as a json-ready map.
+required lists the properties that were present whenever their parent was,
+and is only given outside of arrays, where presence is counted per sample and not per item.
+*/
+func (s *Schema) JSONSchema() map[string]any {
+	root := &schemaNode{properties: make(map[string]*schemaNode)}
+	for _, path := range s.Paths() {
+		root.insert(path)
+	}
+
+	out := s.nodeSchema(root, true)
+	out["x-samples"] = s.Samples
+	return out
+}
+
+/*
+Returns a single JSON Schema document for all the event types.
+Each event type is defined in $defs and the root accepts any one of them.
+*/
+func (s Schemas) JSONSchema() map[string]any {
+	eventTypes := make([]string, 0, len(s))
+	for eventType := range s {
+		eventTypes = append(eventTypes, eventType)
+	}
+	sort.Strings(eventTypes)
+
+	defs := make(map[string]any, len(s))
+	oneOf := make([]any, 0, len(s))
+	for _, eventType := range eventTypes {
+		defs[eventType] = s[eventType].JSONSchema()
+		oneOf = append(oneOf, map[string]any{"$ref": "#/$defs/" + eventType})
+	}
+	return map[string]any{
+		"$schema": JSONSchemaDialect,
+		"$defs":   defs,
+		"oneOf":   oneOf,
+	}
+}
+
+// Saves the JSON Schema of the schemas, see WriteSchemas.
+func WriteJSONSchema(outputFile string, schemas Schemas) error {
+	return writeJSON(outputFile, schemas.JSONSchema())
+}
+
+// Inserts the path, and any missing parents, into the tree.
+func (n *schemaNode) insert(path string) *schemaNode {
+	if path == "" {
+		return n
+	}
+	var parent *schemaNode
+	if strings.HasSuffix(path, "[]") {
+		parent = n.insert(strings.TrimSuffix(path, "[]"))
+		if parent.items == nil {
+			parent.items = &schemaNode{path: path}
+		}
+		return parent.items
+	}
+
+	key := path
+	if i := strings.LastIndexByte(path, '.'); i >= 0 {
+		parent = n.insert(path[:i])
+		key = path[i+1:]
+	} else {
+		parent = n
+	}
+	if parent.properties == nil {
+		parent.properties = make(map[string]*schemaNode)
+	}
+	child, ok := parent.properties[key]
+	if !ok {
+		child = &schemaNode{path: path}
+		parent.properties[key] = child
+	}
+	return child
+}
+
+func (s *Schema) nodeSchema(n *schemaNode, withRequired bool) map[string]any {
+	out := make(map[string]any)
+	if n.path != "" {
+		types := s.Types(n.path)
+		names := make([]string, 0, len(types))
+		for _, t := range types {
+			if name, ok := jsonSchemaTypes[t]; ok {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 1 {
+			out["type"] = names[0]
+		} else if len(names) > 1 {
+			out["type"] = names
+		}
+		out["x-presence"] = s.Presence(n.path)
+	}
+
+	count := s.Samples
+	if stats, ok := s.Fields[n.path]; ok {
+		count = stats.Count
+	}
+
+	if len(n.properties) > 0 {
+		keys := make([]string, 0, len(n.properties))
+		for key := range n.properties {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		properties := make(map[string]any, len(keys))
+		required := make([]string, 0)
+		for _, key := range keys {
+			child := n.properties[key]
+			properties[key] = s.nodeSchema(child, withRequired)
+			if stats, ok := s.Fields[child.path]; withRequired && ok && stats.Count == count {
+				required = append(required, key)
+			}
+		}
+		out["properties"] = properties
+		if len(required) > 0 {
+			out["required"] = required
+		}
+	}
+	if n.items != nil {
+		out["items"] = s.nodeSchema(n.items, false)
+	}
+	return out
+}
@@ -0,0 +1,225 @@
+package infer
+
+/*
+Implements merged schemas over many events.
+
+Where InferFlattenedTypes describes a single json, a Schema describes a whole
+period of the archive: for every path seen it keeps in how many events it was
+present and with which types. Schemas of two periods can then be compared
+(see DiffSchemas) or exported as JSON Schema (see JSONSchema).
+*/
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"stream-parser/graph"
+)
+
+/*
+A single event reduced to its observed paths and their types.
+
+Unlike flatten, every node is recorded (objects and arrays included) and array
+indices are collapsed into "[]", so payload.commits[0].sha and payload.commits[1].sha
+are both payload.commits[].sha.
+
+Sample decodes itself, so it can be used directly as the T of myjson.ParseInParallel.
+every decode allocates a new Fields map, which makes it safe to pass between threads.
+*/
+type Sample struct {
+	EventType string
+	Fields    map[string][]string
+}
+
+func (s *Sample) UnmarshalJSON(data []byte) error {
+	var root any
+	if err := json.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("unmarshal error: %w", err)
+	}
+
+	s.EventType = ""
+	if obj, ok := root.(map[string]any); ok {
+		if eventType, ok := obj["type"].(string); ok {
+			s.EventType = eventType
+		}
+	}
+	s.Fields = make(map[string][]string)
+	collectTypes("", root, s.Fields)
+	return nil
+}
+
+func collectTypes(path string, v any, out map[string][]string) {
+	if path != "" {
+		addType(out, path, typeOf(v))
+	}
+	switch val := v.(type) {
+	case map[string]any:
+		for k, v2 := range val {
+			fullKey := k
+			if path != "" {
+				fullKey = path + "." + k
+			}
+			collectTypes(fullKey, v2, out)
+		}
+	case []any:
+		for _, item := range val {
+			collectTypes(path+"[]", item, out)
+		}
+	}
+}
+
+func addType(out map[string][]string, path string, t string) {
+	for _, seen := range out[path] {
+		if seen == t {
+			return
+		}
+	}
+	out[path] = append(out[path], t)
+}
+
+// Statistics of a single path over all the samples of a Schema.
+type FieldStats struct {
+	Count int            `json:"count"` // number of samples the path was present in
+	Types map[string]int `json:"types"` // number of samples the path had each type in
+}
+
+/*
+The merged schema of many samples of the same event type.
+*/
+type Schema struct {
+	Samples int                    `json:"samples"`
+	Fields  map[string]*FieldStats `json:"fields"`
+}
+
+// Merged schemas keyed by event type (PushEvent, WatchEvent ...)
+type Schemas map[string]*Schema
+
+func NewSchema() *Schema {
+	return &Schema{Fields: make(map[string]*FieldStats)}
+}
+
+// Adds a single sample into the schema.
+func (s *Schema) Add(sample Sample) {
+	s.Samples++
+	for path, types := range sample.Fields {
+		stats := s.field(path)
+		stats.Count++
+		for _, t := range types {
+			stats.Types[t]++
+		}
+	}
+}
+
+// Merges another schema (for example of another worker or another period) into s.
+func (s *Schema) Merge(other *Schema) {
+	s.Samples += other.Samples
+	for path, otherStats := range other.Fields {
+		stats := s.field(path)
+		stats.Count += otherStats.Count
+		for t, count := range otherStats.Types {
+			stats.Types[t] += count
+		}
+	}
+}
+
+func (s *Schema) field(path string) *FieldStats {
+	stats, ok := s.Fields[path]
+	if !ok {
+		stats = &FieldStats{Types: make(map[string]int)}
+		s.Fields[path] = stats
+	}
+	return stats
+}
+
+// The rate [0, 1] of samples in which the path was present.
+func (s *Schema) Presence(path string) float64 {
+	stats, ok := s.Fields[path]
+	if !ok || s.Samples == 0 {
+		return 0
+	}
+	return float64(stats.Count) / float64(s.Samples)
+}
+
+// The sorted types observed for a path.
+func (s *Schema) Types(path string) []string {
+	stats, ok := s.Fields[path]
+	if !ok {
+		return nil
+	}
+	types := make([]string, 0, len(stats.Types))
+	for t := range stats.Types {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// The sorted paths of the schema.
+func (s *Schema) Paths() []string {
+	paths := make([]string, 0, len(s.Fields))
+	for path := range s.Fields {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+/*
+Collects samples into a schema per event type.
+Samples with no "type" field are collected under the empty event type.
+*/
+func SchemaManeger(in <-chan Sample) Schemas {
+	schemas := make(Schemas)
+	for sample := range in {
+		schema, ok := schemas[sample.EventType]
+		if !ok {
+			schema = NewSchema()
+			schemas[sample.EventType] = schema
+		}
+		schema.Add(sample)
+	}
+	return schemas
+}
+
+// Saves the merged schemas as json, to be later loaded by ReadSchemas.
+func WriteSchemas(outputFile string, schemas Schemas) error {
+	return writeJSON(outputFile, schemas)
+}
+
+/*
+Writes value as indented json through a sink, so the file is compressed by its
+extension (see graph.CreateSink).
+*/
+func writeJSON(outputFile string, value any) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	sink, err := graph.CreateSink(outputFile)
+	if err != nil {
+		return err
+	}
+	if _, err := sink.Write(data); err != nil {
+		sink.Close()
+		return fmt.Errorf("%v: %w", outputFile, err)
+	}
+	return sink.Close()
+}
+
+// Loads schemas saved by WriteSchemas, decompressing them when compressed.
+func ReadSchemas(filename string) (Schemas, error) {
+	file, err := graph.OpenSource(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", filename, err)
+	}
+	schemas := make(Schemas)
+	if err := json.Unmarshal(data, &schemas); err != nil {
+		return nil, fmt.Errorf("%v: %w", filename, err)
+	}
+	return schemas, nil
+}
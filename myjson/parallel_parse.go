@@ -69,6 +69,21 @@ Parameters:
 	- sourceType	The type of the file source. either a route to a real file. or http. (takes "file"/"http") 
 */
 func ParseInParallel[T any, R any](files []string, manager ManagerFunc[T,R], sourceType string) (R, error) {
+	result, _, err := ParseInParallelWithOptions(files, manager, sourceType, ParseOptions{})
+	return result, err
+}
+
+/*
+Same as ParseInParallel, but with options for how lines are validated (see ParseOptions),
+returning the summary of the run as well. the summary is also logged once the run is done.
+*/
+func ParseInParallelWithOptions[T any, R any](files []string, manager ManagerFunc[T,R], sourceType string, options ParseOptions) (R, RunSummary, error) {
+	run, err := newParseRun(options)
+	if err != nil {
+		var zero R
+		return zero, RunSummary{}, err
+	}
+
 	var wg sync.WaitGroup
 	var workerWg sync.WaitGroup  // <--- NEW wait group for workers
 	workChan := make(chan T, CHANNEL_BUFFER)
@@ -110,7 +125,7 @@ func ParseInParallel[T any, R any](files []string, manager ManagerFunc[T,R], sou
 		go func() {
 			defer workerWg.Done()
 			for file := range jobs {
-				processFile[T](file, readingMethod, workChan)
+				processFile[T](file, readingMethod, workChan, run)
 
 				curr := atomic.AddInt64(&processed, 1)
 				if curr%10 == 0 || curr == total {
@@ -136,18 +151,24 @@ func ParseInParallel[T any, R any](files []string, manager ManagerFunc[T,R], sou
 
 	// Wait for file producer (jobs channel closer)
 	wg.Wait()
-	return result, nil
+
+	summary, err := run.finish()
+	log.Printf("Summary: %v\n", summary)
+	return result, summary, err
 }
 
-func processFile[T any](filename string, getReader informativeReader, out chan<- T) {
+func processFile[T any](filename string, getReader informativeReader, out chan<- T, run *parseRun) {
+	run.startFile()
 	reader, _, err := getReader(filename)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to open source: %v\n", err);
+		run.failFile()
 		return
 	}
-	err = ProcessNDJSONInParallel(reader, out)
+	err = processNDJSON(reader, out, filename, run)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error processing NDJSON: %v\n", err)
+		run.failFile()
 	}
 	reader.Close()
 }
@@ -167,6 +188,15 @@ Parameters:
 	is inferred based on the type of T.
  */
 func ProcessNDJSONInParallel[T any](originalReader io.Reader, out chan<- T) error {
+	run, _ := newParseRun(ParseOptions{})
+	return processNDJSON(originalReader, out, "", run)
+}
+
+// The implementation of ProcessNDJSONInParallel, checking and counting lines for the run.
+func processNDJSON[T any](originalReader io.Reader, out chan<- T, source string, run *parseRun) error {
+	stats := &fileStats{reasons: make(map[string]int64)}
+	defer run.addFile(stats)
+
 	gz, err := gzip.NewReader(originalReader)
 	if err != nil {
 		return fmt.Errorf("gzip reader error: %v", err)
@@ -176,17 +206,31 @@ func ProcessNDJSONInParallel[T any](originalReader io.Reader, out chan<- T) erro
 	reader := bufio.NewReaderSize(gz, BUFFER_SIZE)
 	var json = jsoniter.ConfigFastest
 
-	var item T;
+	var item, zero T;
 	for {
 		line, err := reader.ReadSlice('\n')
 		if err != nil && err != io.EOF {
 			return fmt.Errorf("read error: %T %v", err, err)
 		}
 		if len(line) > 0 {
+			stats.lines++
+			if !run.check(source, stats.lines, line, stats) {
+				continue
+			}
+			// Unmarshal leaves fields missing from the line untouched, so without the reset
+			// a payload field absent from this event would hold the value of the last one.
+			item = zero
 			if err := json.Unmarshal(line, &item); err != nil {
-				fmt.Printf("JSON unmarshal error: %v\n", err)
+				if !run.options.Validate {
+					fmt.Printf("JSON unmarshal error: %v\n", err)
+				}
+				run.reject(source, stats.lines, line, err, stats)
+				continue
+			}
+			if !run.keep(&item, stats) {
 				continue
 			}
+			stats.accepted++
 			out <- item
 		}
 		if err == io.EOF {
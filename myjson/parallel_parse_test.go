@@ -0,0 +1,39 @@
+package myjson
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+)
+
+type payloadEvent struct {
+	Type    string `json:"type"`
+	Payload struct {
+		Action string `json:"action"`
+		Merged bool   `json:"merged"`
+	} `json:"payload"`
+}
+
+// Fields missing from a line must not keep the values of the previous line.
+func TestProcessNDJSONResetsEvents(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(`{"type":"PullRequestEvent","payload":{"action":"closed","merged":true}}` + "\n"))
+	gz.Write([]byte(`{"type":"PushEvent","payload":{}}` + "\n"))
+	gz.Close()
+
+	run, err := newParseRun(ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(chan payloadEvent, 2)
+	if err := processNDJSON(&buf, out, "test.json.gz", run); err != nil {
+		t.Fatal(err)
+	}
+	close(out)
+	<-out
+	push := <-out
+	if push.Type != "PushEvent" || push.Payload.Action != "" || push.Payload.Merged {
+		t.Errorf("expected an empty payload, got %+v", push)
+	}
+}
@@ -0,0 +1,125 @@
+package myjson
+
+/*
+Implements Parquet and Arrow IPC output of events, flattening BaseEvent into a row per event.
+
+The nested actor, repo and org are flattened into columns, and the payload, whose
+fields differ per event type, is kept as its JSON text next to its action.
+*/
+
+import (
+	"os"
+	"stream-parser/graph"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/parquet-go/parquet-go"
+)
+
+// A flattened BaseEvent.
+type EventRow struct {
+	ID            string  `parquet:"id" arrow:"id"`
+	Type          string  `parquet:"type,dict" arrow:"type"`
+	ActorID       uint32  `parquet:"actor_id" arrow:"actor_id"`
+	ActorLogin    string  `parquet:"actor_login" arrow:"actor_login"`
+	RepoID        uint32  `parquet:"repo_id" arrow:"repo_id"`
+	RepoName      string  `parquet:"repo_name" arrow:"repo_name"`
+	OrgID         *uint32 `parquet:"org_id,optional" arrow:"org_id"`
+	OrgLogin      *string `parquet:"org_login,optional" arrow:"org_login"`
+	Public        bool    `parquet:"public" arrow:"public"`
+	CreatedAt     int64   `parquet:"created_at,timestamp(millisecond)" arrow:"created_at,timestamp"` // unix milliseconds, 0 when unparsable
+	PayloadAction *string `parquet:"payload_action,optional,dict" arrow:"payload_action"`
+	Payload       *string `parquet:"payload,optional" arrow:"payload"` // the payload as JSON
+}
+
+func FlattenEvent(event BaseEvent) EventRow {
+	row := EventRow{
+		ID:         event.ID,
+		Type:       event.Type,
+		ActorID:    event.Actor.ID,
+		ActorLogin: event.Actor.Login,
+		RepoID:     event.Repo.ID,
+		RepoName:   event.Repo.Name,
+		Public:     event.Public,
+	}
+	if event.Org != nil {
+		row.OrgID = &event.Org.ID
+		row.OrgLogin = &event.Org.Login
+	}
+	if createdAt, err := time.Parse(time.RFC3339, event.CreatedAt); err == nil {
+		row.CreatedAt = createdAt.UnixMilli()
+	}
+	if event.Payload != nil {
+		if action, ok := (*event.Payload)["action"].(string); ok {
+			row.PayloadAction = &action
+		}
+		if data, err := jsoniter.ConfigFastest.MarshalToString(*event.Payload); err == nil {
+			row.Payload = &data
+		}
+	}
+	return row
+}
+
+/*
+A manager writing every event it gets into a Parquet file as an EventRow, returning the
+first error encountered. events keep being drained after an error, so the parse finishes.
+*/
+func ParquetEventManeger(outputFile string) ManagerFunc[BaseEvent, error] {
+	return func(in <-chan BaseEvent) error {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			for range in {
+			}
+			return err
+		}
+		defer file.Close()
+
+		writer := parquet.NewGenericWriter[EventRow](file, parquet.Compression(&parquet.Zstd))
+		batch := make([]EventRow, 0, 4096)
+		for event := range in {
+			if err != nil {
+				continue
+			}
+			batch = append(batch, FlattenEvent(event))
+			if len(batch) == cap(batch) {
+				_, err = writer.Write(batch)
+				batch = batch[:0]
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := writer.Write(batch); err != nil {
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		return file.Close()
+	}
+}
+
+/*
+A manager writing every event it gets into an Arrow IPC file as an EventRow, see
+graph.CreateArrowFile and ParquetEventManeger.
+*/
+func ArrowEventManeger(outputFile string) ManagerFunc[BaseEvent, error] {
+	return func(in <-chan BaseEvent) error {
+		writer, err := graph.CreateArrowFile[EventRow](outputFile)
+		if err != nil {
+			for range in {
+			}
+			return err
+		}
+		for event := range in {
+			if err == nil {
+				err = writer.Write(FlattenEvent(event))
+			}
+		}
+		if err != nil {
+			writer.Close()
+			return err
+		}
+		return writer.Close()
+	}
+}
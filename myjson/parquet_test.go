@@ -0,0 +1,63 @@
+package myjson
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestParquetEventManeger(t *testing.T) {
+	events := make(chan BaseEvent, 2)
+	events <- BaseEvent{ID: "1", Type: "IssuesEvent", Actor: Actor{ID: 1, Login: "alice"}, Repo: Repo{ID: 2, Name: "a/b"},
+		CreatedAt: "2025-01-01T00:00:00Z", Payload: &Payload{"action": "opened"}, Org: &Org{ID: 3, Login: "a"}}
+	events <- BaseEvent{ID: "2", Type: "WatchEvent", Actor: Actor{ID: 4}, Repo: Repo{ID: 5}}
+	close(events)
+
+	filename := filepath.Join(t.TempDir(), "events.parquet")
+	if err := ParquetEventManeger(filename)(events); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := parquet.ReadFile[EventRow](filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %+v", rows)
+	}
+	first, second := rows[0], rows[1]
+	if first.ActorLogin != "alice" || *first.OrgID != 3 || *first.PayloadAction != "opened" || first.CreatedAt != 1735689600000 {
+		t.Errorf("unexpected first row %+v", first)
+	}
+	if *first.Payload != `{"action":"opened"}` {
+		t.Errorf("unexpected payload %v", *first.Payload)
+	}
+	if second.OrgID != nil || second.Payload != nil || second.CreatedAt != 0 {
+		t.Errorf("unexpected second row %+v", second)
+	}
+}
+
+func TestArrowEventManeger(t *testing.T) {
+	events := make(chan BaseEvent, 1)
+	events <- BaseEvent{ID: "1", Type: "IssuesEvent", Actor: Actor{ID: 1, Login: "alice"}, Org: &Org{ID: 3, Login: "a"}}
+	close(events)
+
+	filename := filepath.Join(t.TempDir(), "events.arrow")
+	if err := ArrowEventManeger(filename)(events); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte("ARROW1")) || !bytes.HasSuffix(data, []byte("ARROW1")) {
+		t.Fatalf("missing arrow file magic")
+	}
+	for _, value := range []string{"actor_login", "payload_action", "alice", "IssuesEvent"} {
+		if !bytes.Contains(data, []byte(value)) {
+			t.Errorf("arrow file misses %s", value)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package myjson
+
+/*
+Implements the validation mode of ParseInParallel.
+
+In validation mode every line is also checked to be an event holding the fields
+all of the managers rely on (actor, repo, type, created_at). Lines that fail
+the check, or fail to unmarshal at all, are rejected, and when a quarantine file
+is given they are written into it with the reason and where they came from.
+*/
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+/*
+Options changing the way ParseInParallelWithOptions treats the parsed lines.
+The zero value behaves as ParseInParallel always did.
+*/
+type ParseOptions struct {
+	Validate       bool          // Reject events missing the required fields (see ValidateEvent).
+	QuarantineFile string        // If set, rejected lines are written into this file as NDJSON.
+	Filters        []LineFilter  // Lines any of the filters drop are skipped before unmarshaling.
+	EventFilters   []EventFilter // Events any of the filters drop are skipped once unmarshaled.
+}
+
+/*
+Decides for a raw line whether to keep it, before it is unmarshaled into T.
+Unlike validation, dropped lines are not rejected and not quarantined, only counted.
+*/
+type LineFilter func(line []byte) bool
+
+/*
+Decides for an unmarshaled event, a pointer to the T of the run, whether to keep it.
+for filters needing a field of the event, which would otherwise be decoded twice.
+dropped events are counted as filtered, like dropped lines.
+*/
+type EventFilter func(event any) bool
+
+// The index path of the Actor.Login field per event type, nil for types with no such field.
+var actorLoginFields sync.Map
+
+/*
+The login of the actor of an event, read from its Actor.Login string field. ok is false
+for events with no such field, whose T does not decode the login.
+*/
+func ActorLogin(event any) (string, bool) {
+	value := reflect.ValueOf(event)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return "", false
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return "", false
+	}
+	index, ok := actorLoginFields.Load(value.Type())
+	if !ok {
+		index, _ = actorLoginFields.LoadOrStore(value.Type(), actorLoginIndex(value.Type()))
+	}
+	path := index.([]int)
+	if path == nil {
+		return "", false
+	}
+	return value.FieldByIndex(path).String(), true
+}
+
+func actorLoginIndex(t reflect.Type) []int {
+	actor, ok := t.FieldByName("Actor")
+	if !ok || actor.Type.Kind() != reflect.Struct {
+		return nil
+	}
+	login, ok := actor.Type.FieldByName("Login")
+	if !ok || login.Type.Kind() != reflect.String {
+		return nil
+	}
+	return append(append([]int{}, actor.Index...), login.Index...)
+}
+
+// Counts of a single run of ParseInParallelWithOptions.
+type RunSummary struct {
+	Files    int64
+	Lines    int64
+	Accepted int64
+	Rejected int64
+	Filtered int64            // lines dropped by the ParseOptions.Filters and EventFilters
+	Failed   int64            // sources that could not be opened or read to the end
+	Reasons  map[string]int64 // rejected lines per reason
+}
+
+// A line written into the quarantine file.
+type QuarantineRecord struct {
+	Source string `json:"source"` // the file or url the line was read from
+	Line   int64  `json:"line"`   // 1 based line number within the decompressed source
+	Reason string `json:"reason"`
+	Error  string `json:"error,omitempty"`
+	Raw    string `json:"raw"`
+}
+
+// Reasons for rejecting a line.
+const (
+	ReasonUnmarshal        = "unmarshal"
+	ReasonMissingActor     = "missing_actor"
+	ReasonMissingRepo      = "missing_repo"
+	ReasonMissingType      = "missing_type"
+	ReasonMissingCreatedAt = "missing_created_at"
+	ReasonInvalidCreatedAt = "invalid_created_at"
+)
+
+// The fields checked in validation mode, decoded separately from the T the caller asked for.
+type requiredFields struct {
+	Type      string `json:"type"`
+	CreatedAt string `json:"created_at"`
+	Actor     *struct {
+		ID uint32 `json:"id"`
+	} `json:"actor"`
+	Repo *struct {
+		ID uint32 `json:"id"`
+	} `json:"repo"`
+}
+
+// An error of a line that was rejected, holding one of the Reason constants.
+type RejectError struct {
+	Reason string
+	Err    error
+}
+
+func (e *RejectError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Reason, e.Err)
+	}
+	return e.Reason
+}
+
+func (e *RejectError) Unwrap() error {
+	return e.Err
+}
+
+/*
+Checks that a raw event line holds an actor, a repo, a type and a valid created_at.
+returns nil for valid events, and a *RejectError otherwise.
+*/
+func ValidateEvent(line []byte) error {
+	var fields requiredFields
+	if err := jsoniter.ConfigFastest.Unmarshal(line, &fields); err != nil {
+		return &RejectError{Reason: ReasonUnmarshal, Err: err}
+	}
+	switch {
+	case fields.Actor == nil || fields.Actor.ID == 0:
+		return &RejectError{Reason: ReasonMissingActor}
+	case fields.Repo == nil || fields.Repo.ID == 0:
+		return &RejectError{Reason: ReasonMissingRepo}
+	case fields.Type == "":
+		return &RejectError{Reason: ReasonMissingType}
+	case fields.CreatedAt == "":
+		return &RejectError{Reason: ReasonMissingCreatedAt}
+	}
+	if _, err := time.Parse(time.RFC3339, fields.CreatedAt); err != nil {
+		return &RejectError{Reason: ReasonInvalidCreatedAt, Err: err}
+	}
+	return nil
+}
+
+/*
+The state shared by all the workers of a single run.
+counters are collected per file (see fileStats) and added once, to avoid contention.
+*/
+type parseRun struct {
+	options ParseOptions
+
+	mu         sync.Mutex
+	summary    RunSummary
+	quarantine *os.File
+	writer     *bufio.Writer
+}
+
+type fileStats struct {
+	lines    int64
+	accepted int64
+	filtered int64
+	reasons  map[string]int64
+}
+
+func newParseRun(options ParseOptions) (*parseRun, error) {
+	run := &parseRun{
+		options: options,
+		summary: RunSummary{Reasons: make(map[string]int64)},
+	}
+	if options.QuarantineFile != "" {
+		file, err := os.OpenFile(options.QuarantineFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("opening quarantine file: %w", err)
+		}
+		run.quarantine = file
+		run.writer = bufio.NewWriter(file)
+	}
+	return run, nil
+}
+
+// Checks a line, returning whether it should be unmarshaled and passed on.
+func (run *parseRun) check(source string, lineNumber int64, line []byte, stats *fileStats) bool {
+	for _, keep := range run.options.Filters {
+		if !keep(line) {
+			stats.filtered++
+			return false
+		}
+	}
+	if !run.options.Validate {
+		return true
+	}
+	if err := ValidateEvent(line); err != nil {
+		run.reject(source, lineNumber, line, err, stats)
+		return false
+	}
+	return true
+}
+
+// Checks an unmarshaled event, returning whether it should be passed on.
+func (run *parseRun) keep(event any, stats *fileStats) bool {
+	for _, keep := range run.options.EventFilters {
+		if !keep(event) {
+			stats.filtered++
+			return false
+		}
+	}
+	return true
+}
+
+func (run *parseRun) reject(source string, lineNumber int64, line []byte, err error, stats *fileStats) {
+	reason := ReasonUnmarshal
+	var cause error = err
+	if rejectErr, ok := err.(*RejectError); ok {
+		reason = rejectErr.Reason
+		cause = rejectErr.Err
+	}
+	stats.reasons[reason]++
+
+	if run.writer == nil {
+		return
+	}
+	record := QuarantineRecord{
+		Source: source,
+		Line:   lineNumber,
+		Reason: reason,
+		Raw:    strings.TrimRight(string(line), "\r\n"),
+	}
+	if cause != nil {
+		record.Error = cause.Error()
+	}
+	data, err := jsoniter.ConfigFastest.Marshal(record)
+	if err != nil {
+		log.Printf("Error encoding quarantine record: %v\n", err)
+		return
+	}
+
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	run.writer.Write(data)
+	run.writer.WriteByte('\n')
+}
+
+// Counts a source before it is opened, so sources failing to open are counted as well.
+func (run *parseRun) startFile() {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	run.summary.Files++
+}
+
+func (run *parseRun) addFile(stats *fileStats) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	run.summary.Lines += stats.lines
+	run.summary.Accepted += stats.accepted
+	run.summary.Filtered += stats.filtered
+	for reason, count := range stats.reasons {
+		run.summary.Reasons[reason] += count
+		run.summary.Rejected += count
+	}
+}
+
+func (run *parseRun) failFile() {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	run.summary.Failed++
+}
+
+// Flushes the quarantine file, and returns the summary of the run.
+func (run *parseRun) finish() (RunSummary, error) {
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	if run.quarantine == nil {
+		return run.summary, nil
+	}
+	if err := run.writer.Flush(); err != nil {
+		run.quarantine.Close()
+		return run.summary, fmt.Errorf("writing quarantine file: %w", err)
+	}
+	return run.summary, run.quarantine.Close()
+}
+
+func (s RunSummary) String() string {
+	reasons := make([]string, 0, len(s.Reasons))
+	for reason, count := range s.Reasons {
+		reasons = append(reasons, fmt.Sprintf("%s=%d", reason, count))
+	}
+	sort.Strings(reasons)
+	return fmt.Sprintf("files: %d | failed: %d | lines: %d | accepted: %d | filtered: %d | rejected: %d [%s]",
+		s.Files, s.Failed, s.Lines, s.Accepted, s.Filtered, s.Rejected, strings.Join(reasons, " "))
+}
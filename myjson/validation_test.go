@@ -0,0 +1,117 @@
+package myjson
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+func TestValidationQuarantine(t *testing.T) {
+	lines := []string{
+		`{"type":"PushEvent","actor":{"id":1},"repo":{"id":2},"created_at":"2025-01-01T00:00:00Z"}`,
+		`{"type":"PushEvent","repo":{"id":2},"created_at":"2025-01-01T00:00:00Z"}`,
+		`{"type":"PushEvent","actor":{"id":1},"repo":{"id":2},"created_at":"yesterday"}`,
+		`{"type":`,
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	for _, line := range lines {
+		gz.Write([]byte(line + "\n"))
+	}
+	gz.Close()
+
+	quarantine := filepath.Join(t.TempDir(), "quarantine.ndjson")
+	run, err := newParseRun(ParseOptions{Validate: true, QuarantineFile: quarantine})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(chan BaseEvent, len(lines))
+	if err := processNDJSON(&buf, out, "test.json.gz", run); err != nil {
+		t.Fatal(err)
+	}
+	close(out)
+	summary, err := run.finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(out) != 1 || summary.Accepted != 1 || summary.Lines != 4 || summary.Rejected != 3 {
+		t.Fatalf("unexpected summary %v with %d events", summary, len(out))
+	}
+
+	file, err := os.Open(quarantine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var records []QuarantineRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record QuarantineRecord
+		if err := jsoniter.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, record)
+	}
+
+	expected := []QuarantineRecord{
+		{Source: "test.json.gz", Line: 2, Reason: ReasonMissingActor, Raw: lines[1]},
+		{Source: "test.json.gz", Line: 3, Reason: ReasonInvalidCreatedAt, Raw: lines[2]},
+		{Source: "test.json.gz", Line: 4, Reason: ReasonUnmarshal, Raw: lines[3]},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("expected %d records, got %v", len(expected), records)
+	}
+	for i, record := range records {
+		record.Error = ""
+		if record != expected[i] {
+			t.Errorf("record %d: expected %+v, got %+v", i, expected[i], record)
+		}
+	}
+}
+
+func TestFailedSources(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing.json.gz")
+	count := func(events <-chan BaseEvent) int {
+		n := 0
+		for range events {
+			n++
+		}
+		return n
+	}
+	_, summary, err := ParseInParallelWithOptions([]string{missing}, count, "file", ParseOptions{})
+	if err != nil || summary.Failed != 1 || summary.Files != 1 {
+		t.Fatalf("expected the missing source to be counted as failed, got %v %v", summary, err)
+	}
+}
+
+func TestEventFilters(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(`{"actor":{"id":1,"login":"alice"}}` + "\n" + `{"actor":{"id":2,"login":"ci[bot]"}}` + "\n"))
+	gz.Close()
+
+	dropBots := func(event any) bool {
+		login, ok := ActorLogin(event)
+		return !ok || login != "ci[bot]"
+	}
+	run, _ := newParseRun(ParseOptions{EventFilters: []EventFilter{dropBots}})
+	out := make(chan BaseEvent, 2)
+	if err := processNDJSON(&buf, out, "test.json.gz", run); err != nil {
+		t.Fatal(err)
+	}
+	close(out)
+	summary, _ := run.finish()
+	if len(out) != 1 || (<-out).Actor.Login != "alice" || summary.Accepted != 1 || summary.Filtered != 1 {
+		t.Errorf("expected the bot event to be filtered, got %v", summary)
+	}
+
+	if _, ok := ActorLogin(&struct{ Actor struct{ ID uint32 } }{}); ok {
+		t.Errorf("events with no actor login should have none")
+	}
+}